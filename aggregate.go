@@ -0,0 +1,134 @@
+package critbitgo
+
+import (
+	"reflect"
+)
+
+// Returns the smallest table equivalent to the table in the longest prefix matching.
+// Every address matches a route with a value equal to that of the route it matches in the table,
+// and addresses matching no route still match no route.
+// Sibling routes with equal values are merged into their supernet, and routes covered by
+// a route with an equal value are dropped.
+// Values are compared by `equal`, or reflect.DeepEqual if `equal` is nil, and a value of the
+// result is that of one of the routes it replaces.
+// The result has the same options as the table.
+func (n *NetOf[V]) Aggregate(equal func(a, b V) bool) *NetOf[V] {
+	if equal == nil {
+		equal = func(a, b V) bool {
+			return reflect.DeepEqual(a, b)
+		}
+	}
+	r := &NetOf[V]{opts: n.opts}
+	for family, t := range n.tries {
+		r.tries[family] = &TrieOf[V]{opts: t.opts}
+		first, _, ok := t.Min()
+		if !ok {
+			continue
+		}
+
+		// the binary tree of all prefixes of routes
+		root := &aggNode[V]{}
+		t.Walk(nil, func(key []byte, value V) bool {
+			p := root
+			for i := 0; i < int(key[len(key)-1]); i++ {
+				bit := key[i>>3] >> (7 - uint(i&0x07)) & 1
+				if p.child[bit] == nil {
+					p.child[bit] = &aggNode[V]{}
+				}
+				p = p.child[bit]
+			}
+			p.route, p.value = true, value
+			return true
+		})
+
+		a := &aggregator[V]{
+			equal: equal,
+			b:     newBuilder(r.tries[family]),
+			addr:  make([]byte, len(first)-1),
+		}
+		a.prepare(root, nil)
+		a.emit(root, 0, nil)
+		a.b.finish()
+	}
+	return r
+}
+
+// aggregator finds the smallest table by ORTC (Optimal Routing Table Constructor).
+// Addresses matching no route cannot be expressed under a route, so that only subtrees
+// whose addresses all match routes are aggregated.
+type aggregator[V any] struct {
+	equal func(a, b V) bool
+	b     *builder[V]
+	addr  []byte // the address of the current node
+}
+
+type aggNode[V any] struct {
+	child     [2]*aggNode[V]
+	route     bool // whether a route of the table
+	value     V
+	uncovered bool // whether some addresses of the subtree match no route
+	set       []V  // values which may be assigned to the node in the smallest table
+}
+
+// filling the tree so that every node has zero or two children, and computing the sets of
+// values bottom up. `inherited` is the value of the closest route above, or nil.
+func (a *aggregator[V]) prepare(n *aggNode[V], inherited *V) {
+	if n.route {
+		inherited = &n.value
+	}
+	if n.child[0] == nil && n.child[1] == nil {
+		if inherited == nil {
+			n.uncovered = true
+		} else {
+			n.set = []V{*inherited}
+		}
+		return
+	}
+	for i, c := range n.child {
+		if c == nil {
+			c = &aggNode[V]{}
+			n.child[i] = c
+		}
+		a.prepare(c, inherited)
+	}
+	c0, c1 := n.child[0], n.child[1]
+	if n.uncovered = c0.uncovered || c1.uncovered; !n.uncovered {
+		// the intersection of both sets, or the union if it is empty
+		for _, v := range c0.set {
+			if a.contains(c1.set, v) {
+				n.set = append(n.set, v)
+			}
+		}
+		if len(n.set) == 0 {
+			n.set = append(append(make([]V, 0, len(c0.set)+len(c1.set)), c0.set...), c1.set...)
+		}
+	}
+}
+
+// adding routes of the smallest table top down, in the order of Walk.
+// `inherited` is the value of the closest route added above, or nil.
+func (a *aggregator[V]) emit(n *aggNode[V], depth int, inherited *V) {
+	if !n.uncovered && (inherited == nil || !a.contains(n.set, *inherited)) {
+		inherited = &n.set[0]
+		key := make([]byte, len(a.addr)+1)
+		copy(key, a.addr)
+		key[len(a.addr)] = byte(depth)
+		a.b.add(a.b.t.storedKey(key), *inherited)
+	}
+	if n.child[0] == nil {
+		return
+	}
+	a.emit(n.child[0], depth+1, inherited)
+	a.addr[depth>>3] |= 0x80 >> uint(depth&0x07)
+	a.emit(n.child[1], depth+1, inherited)
+	a.addr[depth>>3] &^= 0x80 >> uint(depth&0x07)
+}
+
+func (a *aggregator[V]) contains(set []V, v V) bool {
+	for _, w := range set {
+		if a.equal(w, v) {
+			return true
+		}
+	}
+	return false
+}
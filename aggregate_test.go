@@ -0,0 +1,86 @@
+package critbitgo_test
+
+import (
+	"math/rand"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/k-sone/critbitgo"
+)
+
+func TestNetAggregate(t *testing.T) {
+	for _, c := range []struct {
+		routes map[string]string
+		exp    []string
+	}{
+		// siblings
+		{map[string]string{"10.0.0.0/25": "a", "10.0.0.128/25": "a"}, []string{"10.0.0.0/24 a"}},
+		// not siblings
+		{map[string]string{"10.0.1.0/24": "a", "10.0.2.0/24": "a"}, []string{"10.0.1.0/24 a", "10.0.2.0/24 a"}},
+		// a covered route
+		{map[string]string{"10.0.0.0/8": "a", "10.1.0.0/16": "a"}, []string{"10.0.0.0/8 a"}},
+		// a route between them
+		{
+			map[string]string{"10.0.0.0/8": "a", "10.1.0.0/16": "b", "10.1.1.0/24": "a"},
+			[]string{"10.0.0.0/8 a", "10.1.0.0/16 b", "10.1.1.0/24 a"},
+		},
+		// siblings replacing a supernet
+		{
+			map[string]string{"10.0.0.0/8": "a", "10.0.0.0/9": "b", "10.128.0.0/9": "b"},
+			[]string{"10.0.0.0/8 b"},
+		},
+		// the supernet of most values
+		{
+			map[string]string{"192.168.0.0/24": "a", "192.168.1.0/24": "a", "192.168.2.0/24": "a", "192.168.3.0/24": "b"},
+			[]string{"192.168.0.0/22 a", "192.168.3.0/24 b"},
+		},
+		{
+			map[string]string{"2001:db8::/33": "a", "2001:db8:8000::/33": "a", "10.0.0.0/8": "a"},
+			[]string{"10.0.0.0/8 a", "2001:db8::/32 a"},
+		},
+	} {
+		trie := critbitgo.NewNetOf[string]()
+		for r, v := range c.routes {
+			if err := trie.AddCIDR(r, v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		var ret []string
+		trie.Aggregate(nil).Walk(nil, func(r *net.IPNet, v string) bool {
+			ret = append(ret, r.String()+" "+v)
+			return true
+		})
+		if !reflect.DeepEqual(ret, c.exp) {
+			t.Errorf("Aggregate() - %v: failed %s", c.routes, ret)
+		}
+	}
+}
+
+func TestNetAggregateEquivalent(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	for i := 0; i < 300; i++ {
+		// routes in 10.0.0.0/24
+		trie := critbitgo.NewNetOf[int]()
+		for n := random.Intn(30); n > 0; n-- {
+			ones := 22 + random.Intn(11)
+			r := &net.IPNet{IP: net.IPv4(10, 0, 0, byte(random.Intn(256))), Mask: net.CIDRMask(ones, 32)}
+			trie.Add(r, random.Intn(3))
+		}
+		agg := trie.Aggregate(func(a, b int) bool { return a == b })
+		if agg.Size() > trie.Size() {
+			t.Fatalf("Aggregate() - more routes %d > %d", agg.Size(), trie.Size())
+		}
+		for a := 0; a < 1024; a++ {
+			ip := net.IPv4(10, 0, byte(a>>8), byte(a))
+			r1, v1, _ := trie.MatchIP(ip)
+			r2, v2, _ := agg.MatchIP(ip)
+			if (r1 == nil) != (r2 == nil) || v1 != v2 {
+				t.Fatalf("Aggregate() - %s: not equivalent %v %d, %v %d", ip, r1, v1, r2, v2)
+			}
+		}
+		if again := agg.Aggregate(nil); again.Size() != agg.Size() {
+			t.Fatalf("Aggregate() - not the smallest %d, %d", again.Size(), agg.Size())
+		}
+	}
+}
@@ -0,0 +1,631 @@
+package critbitgo
+
+import (
+	"bytes"
+	"slices"
+)
+
+const (
+	// a handle refers to an external node if its most significant bit is set, or an internal node otherwise.
+	arenaExternalFlag = 1 << 31
+	// the position of the root, instead of the handle of the parent.
+	arenaRoot = 1<<32 - 1
+	// nodes and bytes of keys left out of the tree before it is compacted, in addition to those in the tree.
+	arenaSlack = 1024
+)
+
+// arena holds the nodes of a tree with WithArena in slices, where nodes refer to each other
+// by integer handles instead of pointers, and packs keys into a contiguous byte slice,
+// so that the garbage collector does not scan the tree unless values contain pointers.
+//
+// Slots of deleted nodes are reused. A snapshot shares the slices up to their current lengths,
+// and nodes below those lengths are copied before being changed and are never reused, so that
+// the snapshot is not affected. Bytes of keys are never changed once appended.
+// The tree is copied into a new arena when more than half of its nodes or keys are left out of it.
+type arena[V any] struct {
+	internals []arenaInternal
+	counts    []int // numbers of keys in the subtrees of internal nodes (with WithOrderStatistics)
+	externals []arenaExternal[V]
+	keys      []byte
+	free      [2][]uint32 // free slots of internal and external nodes
+	shared    [2]uint32   // numbers of internal and external nodes shared with snapshots
+	root      uint32
+	dead      int // nodes shared with snapshots and no longer in the tree
+	garbage   int // bytes of keys no longer in the tree
+	counted   bool
+}
+
+type arenaInternal struct {
+	child [2]uint32
+	critBit
+}
+
+type arenaExternal[V any] struct {
+	off   int // offset of the key in the arena
+	len   int
+	value V
+}
+
+// creating an arena holding a single key.
+func newArena[V any](key []byte, value V, counted bool) *arena[V] {
+	a := &arena[V]{counted: counted}
+	a.root = a.newExternal(a.appendKey(key), len(key), value)
+	return a
+}
+
+// returning the key of an external node.
+func (a *arena[V]) key(ref uint32) []byte {
+	e := &a.externals[ref&^arenaExternalFlag]
+	return a.keys[e.off : e.off+e.len : e.off+e.len]
+}
+
+// returning the value of an external node.
+func (a *arena[V]) value(ref uint32) V {
+	return a.externals[ref&^arenaExternalFlag].value
+}
+
+// appending a key to the arena, returning its offset.
+func (a *arena[V]) appendKey(key []byte) int {
+	off := len(a.keys)
+	a.keys = append(a.keys, key...)
+	return off
+}
+
+// allocating an external node.
+func (a *arena[V]) newExternal(off, length int, value V) uint32 {
+	e := arenaExternal[V]{off: off, len: length, value: value}
+	if n := len(a.free[1]); n > 0 {
+		i := a.free[1][n-1]
+		a.free[1] = a.free[1][:n-1]
+		a.externals[i] = e
+		return i | arenaExternalFlag
+	}
+	if len(a.externals) == arenaExternalFlag {
+		panic("critbitgo: too many keys for WithArena")
+	}
+	a.externals = append(a.externals, e)
+	return uint32(len(a.externals)-1) | arenaExternalFlag
+}
+
+// allocating an internal node, with the count of its subtree if counted.
+func (a *arena[V]) newInternal(in arenaInternal, count int) uint32 {
+	if n := len(a.free[0]); n > 0 {
+		i := a.free[0][n-1]
+		a.free[0] = a.free[0][:n-1]
+		a.internals[i] = in
+		if a.counted {
+			a.counts[i] = count
+		}
+		return i
+	}
+	if len(a.internals) == arenaExternalFlag {
+		panic("critbitgo: too many keys for WithArena")
+	}
+	a.internals = append(a.internals, in)
+	if a.counted {
+		a.counts = append(a.counts, count)
+	}
+	return uint32(len(a.internals) - 1)
+}
+
+// releasing a node no longer in the tree, reusing its slot unless it is shared with snapshots.
+func (a *arena[V]) release(ref uint32) {
+	if ref&arenaExternalFlag != 0 {
+		i := ref &^ arenaExternalFlag
+		a.garbage += a.externals[i].len
+		if i < a.shared[1] {
+			a.dead += 1
+			return
+		}
+		a.externals[i] = arenaExternal[V]{}
+		a.free[1] = append(a.free[1], i)
+		return
+	}
+	if ref < a.shared[0] {
+		a.dead += 1
+		return
+	}
+	a.internals[ref] = arenaInternal{}
+	a.free[0] = append(a.free[0], ref)
+}
+
+// returning the handle at a position, which is the root or a child of the internal node `parent`.
+// The pointer is valid until the next allocation.
+func (a *arena[V]) at(parent uint32, direction int) *uint32 {
+	if parent == arenaRoot {
+		return &a.root
+	}
+	return &a.internals[parent].child[direction]
+}
+
+// returning the internal node at a position, copying it if it is shared with snapshots.
+func (a *arena[V]) own(parent uint32, direction int) uint32 {
+	ref := *a.at(parent, direction)
+	if ref >= a.shared[0] {
+		return ref
+	}
+	var count int
+	if a.counted {
+		count = a.counts[ref]
+	}
+	c := a.newInternal(a.internals[ref], count)
+	a.dead += 1
+	*a.at(parent, direction) = c
+	return c
+}
+
+// owning internal nodes on the path to `key` above `crit` and adding `delta` to their counts,
+// then returning the position below them.
+func (a *arena[V]) descend(key []byte, crit *critBit, delta int) (parent uint32, direction int) {
+	parent = arenaRoot
+	for {
+		ref := *a.at(parent, direction)
+		if ref&arenaExternalFlag != 0 || !a.internals[ref].above(crit) {
+			return
+		}
+		parent = a.own(parent, direction)
+		if a.counted {
+			a.counts[parent] += delta
+		}
+		direction = a.internals[parent].direction(key)
+	}
+}
+
+// searching the tree.
+func (a *arena[V]) search(key []byte) uint32 {
+	ref := a.root
+	for ref&arenaExternalFlag == 0 {
+		in := &a.internals[ref]
+		ref = in.child[in.direction(key)]
+	}
+	return ref
+}
+
+// returns the critical bit of the node that would be created by inserting `key`, or nil if `key` is in the tree.
+func (a *arena[V]) critNode(key []byte) *critBit {
+	offset, bit, cont := criticalBit(a.key(a.search(key)), key)
+	if offset == -1 {
+		return nil
+	}
+	return &critBit{offset: offset, bit: bit, cont: cont}
+}
+
+// insert into the tree (replaceable), returning whether `key` is added.
+func (a *arena[V]) insert(key []byte, value V, replace bool) bool {
+	ref := a.search(key)
+	offset, bit, cont := criticalBit(a.key(ref), key)
+
+	// already exists in the tree
+	if offset == -1 {
+		if !replace {
+			return false
+		}
+		i := ref &^ arenaExternalFlag
+		if i >= a.shared[1] {
+			a.externals[i].value = value
+			return false
+		}
+		// the external node is shared with snapshots, a new one refers to the same key
+		parent, direction := a.descend(key, nil, 0)
+		e := a.newExternal(a.externals[i].off, a.externals[i].len, value)
+		*a.at(parent, direction) = e
+		a.dead += 1
+		return false
+	}
+
+	crit := critBit{offset: offset, bit: bit, cont: cont}
+	e := a.newExternal(a.appendKey(key), len(key), value)
+	parent, direction := a.descend(key, &crit, 1)
+	newNode := arenaInternal{critBit: crit}
+	d := crit.direction(key)
+	newNode.child[d] = e
+	newNode.child[1-d] = *a.at(parent, direction)
+	var count int
+	if a.counted {
+		count = a.count(newNode.child[1-d]) + 1
+	}
+	i := a.newInternal(newNode, count)
+	*a.at(parent, direction) = i
+	return true
+}
+
+// deleting `key` from a tree holding more than one key.
+func (a *arena[V]) delete(key []byte) (value V, ok bool) {
+	ref := a.search(key)
+	if !bytes.Equal(a.key(ref), key) {
+		return
+	}
+	value, ok = a.value(ref), true
+
+	// replacing the parent of the key by its sibling, owning and decreasing counts of nodes above
+	parent, direction := uint32(arenaRoot), 0
+	for {
+		p := *a.at(parent, direction)
+		d := a.internals[p].direction(key)
+		if a.internals[p].child[d] == ref {
+			*a.at(parent, direction) = a.internals[p].child[1-d]
+			a.release(p)
+			a.release(ref)
+			return
+		}
+		parent = a.own(parent, direction)
+		if a.counted {
+			a.counts[parent] -= 1
+		}
+		direction = d
+	}
+}
+
+// returning an arena sharing all nodes and keys with `a` in O(1),
+// both copying shared nodes before changing them.
+func (a *arena[V]) snapshot() *arena[V] {
+	a.shared = [2]uint32{uint32(len(a.internals)), uint32(len(a.externals))}
+	s := *a
+	// appending to the slices of either arena does not overwrite the other
+	s.internals = slices.Clip(a.internals)
+	s.counts = slices.Clip(a.counts)
+	s.externals = slices.Clip(a.externals)
+	s.keys = slices.Clip(a.keys)
+	s.free = [2][]uint32{}
+	return &s
+}
+
+// whether more than half of the nodes or keys are left out of a tree of `size` keys.
+func (a *arena[V]) sparse(size int) bool {
+	return a.dead > 2*size+arenaSlack || 2*a.garbage > len(a.keys)+arenaSlack
+}
+
+// copying the tree into a new arena, leaving out nodes and keys no longer in the tree.
+// Keys returned before keep referring to the old arena.
+func (a *arena[V]) rebuild(size int) *arena[V] {
+	r := &arena[V]{
+		internals: make([]arenaInternal, 0, size-1),
+		externals: make([]arenaExternal[V], 0, size),
+		keys:      make([]byte, 0, len(a.keys)-a.garbage),
+		counted:   a.counted,
+	}
+	if a.counted {
+		r.counts = make([]int, 0, size-1)
+	}
+	r.root = r.copyFrom(a, a.root)
+	return r
+}
+
+// copying the subtree `ref` of another arena, returning its handle in the arena.
+func (a *arena[V]) copyFrom(src *arena[V], ref uint32) uint32 {
+	if ref&arenaExternalFlag != 0 {
+		key := src.key(ref)
+		return a.newExternal(a.appendKey(key), len(key), src.value(ref))
+	}
+	in := src.internals[ref]
+	for i := 0; i < 2; i++ {
+		in.child[i] = a.copyFrom(src, in.child[i])
+	}
+	var count int
+	if a.counted {
+		count = src.counts[ref]
+	}
+	return a.newInternal(in, count)
+}
+
+// returns the top node of elements with a given prefix.
+// If not found, `ok` is false.
+func (a *arena[V]) prefixed(prefix []byte) (top uint32, ok bool) {
+	// walk tree, maintaining top handle
+	p := a.root
+	top = p
+	if len(prefix) > 0 {
+		for p&arenaExternalFlag == 0 {
+			in := &a.internals[p]
+			p = in.child[in.direction(prefix)]
+			if in.offset < len(prefix) {
+				top = p
+			}
+		}
+
+		// check prefix
+		if !bytes.HasPrefix(a.key(p), prefix) {
+			return 0, false
+		}
+	}
+	return top, true
+}
+
+func (a *arena[V]) allprefixed(ref uint32, handle func([]byte, V) bool) bool {
+	if ref&arenaExternalFlag == 0 {
+		in := &a.internals[ref]
+		return a.allprefixed(in.child[0], handle) && a.allprefixed(in.child[1], handle)
+	}
+	return handle(a.key(ref), a.value(ref))
+}
+
+func (a *arena[V]) allprefixedReverse(ref uint32, handle func([]byte, V) bool) bool {
+	if ref&arenaExternalFlag == 0 {
+		in := &a.internals[ref]
+		return a.allprefixedReverse(in.child[1], handle) && a.allprefixedReverse(in.child[0], handle)
+	}
+	return handle(a.key(ref), a.value(ref))
+}
+
+func (a *arena[V]) longestPrefix(ref uint32, key []byte) (k []byte, v V, ok bool) {
+	if ref&arenaExternalFlag == 0 {
+		in := &a.internals[ref]
+		direction := in.direction(key)
+		if k, v, ok := a.longestPrefix(in.child[direction], key); ok {
+			return k, v, ok
+		}
+		if direction == 1 {
+			return a.longestPrefix(in.child[0], key)
+		}
+	} else if k = a.key(ref); bytes.HasPrefix(key, k) {
+		return k, a.value(ref), true
+	}
+	return nil, v, false
+}
+
+// iterating the subtree `ref` from `key` in the same way as walkFrom.
+func (a *arena[V]) walkFrom(ref uint32, key []byte, crit *critBit, inclusive bool, handle func([]byte, V) bool) bool {
+	if ref&arenaExternalFlag == 0 {
+		if in := &a.internals[ref]; in.above(crit) {
+			if in.direction(key) == 1 {
+				return a.walkFrom(in.child[1], key, crit, inclusive, handle)
+			}
+			return a.walkFrom(in.child[0], key, crit, inclusive, handle) && a.allprefixed(in.child[1], handle)
+		}
+	} else if crit == nil {
+		// reached the key itself
+		if inclusive {
+			return handle(a.key(ref), a.value(ref))
+		}
+		return true
+	}
+
+	// all keys of the subtree differ from the key at the critical bit
+	if crit.direction(key) == 0 {
+		return a.allprefixed(ref, handle)
+	}
+	return true
+}
+
+// iterating the subtree `ref` from `key` in reverse order in the same way as walkFromReverse.
+func (a *arena[V]) walkFromReverse(ref uint32, key []byte, crit *critBit, inclusive bool, handle func([]byte, V) bool) bool {
+	if ref&arenaExternalFlag == 0 {
+		if in := &a.internals[ref]; in.above(crit) {
+			if in.direction(key) == 0 {
+				return a.walkFromReverse(in.child[0], key, crit, inclusive, handle)
+			}
+			return a.walkFromReverse(in.child[1], key, crit, inclusive, handle) && a.allprefixedReverse(in.child[0], handle)
+		}
+	} else if crit == nil {
+		// reached the key itself
+		if inclusive {
+			return handle(a.key(ref), a.value(ref))
+		}
+		return true
+	}
+
+	// all keys of the subtree differ from the key at the critical bit
+	if crit.direction(key) == 1 {
+		return a.allprefixedReverse(ref, handle)
+	}
+	return true
+}
+
+// returning the external node at the end of a direction.
+func (a *arena[V]) edge(direction int) uint32 {
+	ref := a.root
+	for ref&arenaExternalFlag == 0 {
+		ref = a.internals[ref].child[direction]
+	}
+	return ref
+}
+
+// return the number of keys less than (or equal to, if `inclusive`) a given key.
+func (a *arena[V]) rank(key []byte, inclusive bool) (r int) {
+	crit := a.critNode(key)
+	ref := a.root
+	for ref&arenaExternalFlag == 0 && a.internals[ref].above(crit) {
+		in := &a.internals[ref]
+		if in.direction(key) == 1 {
+			r += a.count(in.child[0])
+			ref = in.child[1]
+		} else {
+			ref = in.child[0]
+		}
+	}
+
+	if crit == nil {
+		// reached the key itself
+		if inclusive {
+			r += 1
+		}
+	} else if crit.direction(key) == 1 {
+		// all keys of the subtree are less than the key
+		r += a.count(ref)
+	}
+	return
+}
+
+// return the external node at a given index, which must be less than the number of keys.
+func (a *arena[V]) nth(i int) uint32 {
+	ref := a.root
+	for ref&arenaExternalFlag == 0 {
+		in := &a.internals[ref]
+		if c := a.count(in.child[0]); i >= c {
+			i -= c
+			ref = in.child[1]
+		} else {
+			ref = in.child[0]
+		}
+	}
+	return ref
+}
+
+// return the number of keys in the subtree.
+func (a *arena[V]) count(ref uint32) (c int) {
+	switch {
+	case ref&arenaExternalFlag != 0:
+		return 1
+	case a.counted:
+		return a.counts[ref]
+	}
+	a.allprefixed(ref, func([]byte, V) bool {
+		c += 1
+		return true
+	})
+	return
+}
+
+// filling the number of keys in each subtree.
+func (a *arena[V]) countNode(ref uint32) int {
+	if ref&arenaExternalFlag != 0 {
+		return 1
+	}
+	c := a.countNode(a.internals[ref].child[0]) + a.countNode(a.internals[ref].child[1])
+	a.counts[ref] = c
+	return c
+}
+
+// copying the subtree `ref` into nodes allocated separately, which refer to keys in the arena.
+func (a *arena[V]) expand(ref uint32) node[V] {
+	if ref&arenaExternalFlag != 0 {
+		return node[V]{external: &external[V]{key: a.key(ref), value: a.value(ref)}}
+	}
+	in := newInternal(internal[V]{critBit: a.internals[ref].critBit}, a.counted)
+	for i := 0; i < 2; i++ {
+		in.child[i] = a.expand(a.internals[ref].child[i])
+	}
+	if a.counted {
+		in.counted().count = a.counts[ref]
+	}
+	return node[V]{internal: in}
+}
+
+// returns the route of the longest mask matching `key` in the same way as lookup.
+func (a *arena[V]) lookup(ref uint32, key []byte, backtracking bool) (uint32, bool) {
+	if ref&arenaExternalFlag == 0 {
+		in := &a.internals[ref]
+		var direction int
+		if in.offset == len(key)-1 {
+			// selecting the larger side when comparing the mask
+			direction = 1
+		} else if backtracking {
+			direction = 0
+		} else {
+			direction = in.direction(key)
+		}
+
+		if c, ok := a.lookup(in.child[direction], key, backtracking); ok {
+			return c, true
+		}
+		if direction == 1 {
+			// search other node
+			return a.lookup(in.child[0], key, true)
+		}
+		return 0, false
+	}
+	route := a.key(ref)
+	return ref, len(route) == len(key) && netRouteMatches(route, key)
+}
+
+// iterating routes matching `key` in the same way as walkMatch.
+func (a *arena[V]) walkMatch(ref uint32, key []byte, handle func([]byte, V) bool) bool {
+	if ref&arenaExternalFlag == 0 {
+		in := &a.internals[ref]
+		if !a.walkMatch(in.child[0], key, handle) {
+			return false
+		}
+
+		if in.offset >= len(key)-1 || key[in.offset]&in.bit > 0 {
+			return a.walkMatch(in.child[1], key, handle)
+		}
+		return true
+	}
+
+	route := a.key(ref)
+	if !netRouteMatches(route, key) {
+		return true
+	}
+	return handle(route, a.value(ref))
+}
+
+// insert into a tree with WithArena.
+func (t *TrieOf[V]) arenaInsert(key []byte, value V, replace bool) bool {
+	// an empty tree
+	if t.arena == nil {
+		t.arena = newArena(key, value, t.opts.counted)
+		t.size = 1
+		return true
+	}
+	if !t.arena.insert(key, value, replace) {
+		return false
+	}
+	t.size += 1
+	t.arenaCompact()
+	return true
+}
+
+// deleting elements from a tree with WithArena.
+func (t *TrieOf[V]) arenaDelete(key []byte) (value V, ok bool) {
+	a := t.arena
+	if t.size == 1 {
+		// releasing the arena with the last key
+		if ref := a.root; bytes.Equal(a.key(ref), key) {
+			value, ok = a.value(ref), true
+			t.arena = nil
+			t.size = 0
+		}
+		return
+	}
+	if value, ok = a.delete(key); ok {
+		t.size -= 1
+		t.arenaCompact()
+	}
+	return
+}
+
+// copying the tree into a new arena if more than half of the current arena is not used.
+func (t *TrieOf[V]) arenaCompact() {
+	if t.arena.sparse(t.size) {
+		t.arena = t.arena.rebuild(t.size)
+	}
+}
+
+// adding a key larger than all keys added so far into a tree with WithArena, in the same way as add.
+func (b *builder[V]) addArena(key []byte, value V) error {
+	// an empty tree
+	if b.t.arena == nil {
+		b.t.arena = newArena(key, value, b.t.opts.counted)
+		b.arenaLast = b.t.arena.root
+		b.t.size = 1
+		return nil
+	}
+
+	a := b.t.arena
+	newOffset, newBit, newCont := criticalBit(a.key(b.arenaLast), key)
+	if newOffset == -1 {
+		return ErrDuplicateKey
+	}
+	newNode := arenaInternal{critBit: critBit{offset: newOffset, bit: newBit, cont: newCont}}
+	if newNode.direction(key) == 0 {
+		return ErrUnsortedKeys
+	}
+
+	// finding the position on the right spine
+	for len(b.arenaSpine) > 0 && !a.internals[b.arenaSpine[len(b.arenaSpine)-1]].above(&newNode.critBit) {
+		b.arenaSpine = b.arenaSpine[:len(b.arenaSpine)-1]
+	}
+	parent := uint32(arenaRoot)
+	if len(b.arenaSpine) > 0 {
+		parent = b.arenaSpine[len(b.arenaSpine)-1]
+	}
+
+	b.arenaLast = a.newExternal(a.appendKey(key), len(key), value)
+	newNode.child[0] = *a.at(parent, 1)
+	newNode.child[1] = b.arenaLast
+	in := a.newInternal(newNode, 0)
+	*a.at(parent, 1) = in
+	b.arenaSpine = append(b.arenaSpine, in)
+	b.t.size += 1
+	return nil
+}
@@ -0,0 +1,269 @@
+package critbitgo_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math/rand"
+	"net/netip"
+	"reflect"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+
+	"github.com/k-sone/critbitgo"
+)
+
+func TestArena(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	genKey := randomKey(random, 5)
+
+	for _, opts := range [][]critbitgo.Option{{critbitgo.WithArena()}, {critbitgo.WithArena(), critbitgo.WithOrderStatistics()}} {
+		trie := critbitgo.NewTrieOf[int](opts...)
+		model := make(map[string]int)
+		var snapshot *critbitgo.PersistentTrieOf[int]
+		var snapshotModel map[string]int
+		type retainedKey struct {
+			key []byte
+			exp string
+		}
+		var retained []retainedKey
+		for i := 0; i < 5000; i++ {
+			key := genKey()
+			switch random.Intn(3) {
+			case 0:
+				v1, ok1 := trie.Delete(key)
+				v2, ok2 := model[string(key)]
+				if v1 != v2 || ok1 != ok2 {
+					t.Fatalf("Delete() - %x: invalid result %d, %v", key, v1, ok1)
+				}
+				delete(model, string(key))
+			case 1:
+				_, ok := model[string(key)]
+				if trie.Insert(key, i) == ok {
+					t.Fatalf("Insert() - %x: invalid result %v", key, ok)
+				}
+				if !ok {
+					model[string(key)] = i
+				}
+			default:
+				trie.Set(key, i)
+				model[string(key)] = i
+			}
+			// the buffer of the key is reused
+			for j := range key {
+				key[j] = 0xee
+			}
+
+			if k, _, ok := trie.LongestPrefix(genKey()); ok {
+				retained = append(retained, retainedKey{k, string(k)})
+			}
+			if random.Intn(500) == 0 {
+				snapshot, snapshotModel = trie.Snapshot(), make(map[string]int)
+				for k, v := range model {
+					snapshotModel[k] = v
+				}
+			}
+			if i%100 == 0 {
+				if err := trie.Validate(); err != nil {
+					t.Fatalf("Validate() - %s", err)
+				}
+				if m := toMap(trie); !reflect.DeepEqual(m, model) {
+					t.Fatalf("invalid elems\n%v\n%v", m, model)
+				}
+				if snapshot != nil && !reflect.DeepEqual(toMap(snapshot), snapshotModel) {
+					t.Fatal("Snapshot() - changed by the original")
+				}
+				// keys returned before are not changed by compaction
+				for _, r := range retained {
+					if string(r.key) != r.exp {
+						t.Fatalf("retained key changed %x, %x", r.key, r.exp)
+					}
+				}
+			}
+		}
+
+		// a new version of a snapshot is copied out of the arena
+		if snapshot != nil {
+			key := genKey()
+			version := snapshot.Set(key, -1)
+			snapshotModel[string(key)] = -1
+			if !reflect.DeepEqual(toMap(version), snapshotModel) {
+				t.Fatal("Set() - invalid new version of a snapshot")
+			}
+		}
+
+		// set operations allocate the new tree in its own arena
+		other := critbitgo.NewTrieOf[int]()
+		union, intersect := make(map[string]int), make(map[string]int)
+		for k, v := range model {
+			union[k] = v
+			if random.Intn(2) == 0 {
+				other.Set([]byte(k), -v)
+				union[k], intersect[k] = 0, 0
+			}
+		}
+		sum := func(_ []byte, a, b int) int {
+			return a + b
+		}
+		for j, r := range []*critbitgo.TrieOf[int]{trie.Union(other, sum), trie.Intersect(other, sum)} {
+			if err := r.Validate(); err != nil {
+				t.Fatalf("Validate() - set operation %d: %s", j, err)
+			}
+			if m := toMap(r); !reflect.DeepEqual(m, []map[string]int{union, intersect}[j]) {
+				t.Fatalf("set operation %d - invalid elems\n%v", j, m)
+			}
+		}
+
+		clone := trie.Clone()
+		trie.Clear()
+		if trie.Size() != 0 || trie.Contains(nil) {
+			t.Error("Clear() - not cleared")
+		}
+		if m := toMap(clone); !reflect.DeepEqual(m, model) {
+			t.Errorf("Clone() - invalid elems\n%v\n%v", m, model)
+		}
+	}
+}
+
+func TestArenaLayout(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	genKey := randomKey(random, 6)
+
+	// a tree in an arena has the same shape as the tree of the same keys without it
+	codec := critbitgo.WithValueCodec[[]byte](critbitgo.BytesCodec{})
+	trie := critbitgo.NewTrieOf[[]byte](codec, critbitgo.WithArena())
+	plain := critbitgo.NewTrieOf[[]byte](codec)
+	for i := 0; i < 1000; i++ {
+		key := genKey()
+		if random.Intn(3) == 0 {
+			trie.Delete(key)
+			plain.Delete(key)
+		} else {
+			trie.Set(key, key)
+			plain.Set(key, key)
+		}
+	}
+
+	var b1, b2 bytes.Buffer
+	if err := trie.WriteFrozen(&b1); err != nil {
+		t.Fatalf("WriteFrozen() - error occurred %s", err)
+	}
+	plain.WriteFrozen(&b2)
+	if !bytes.Equal(b1.Bytes(), b2.Bytes()) {
+		t.Error("WriteFrozen() - different from the tree without an arena")
+	}
+	b1.Reset()
+	b2.Reset()
+	trie.Dump(&b1)
+	plain.Dump(&b2)
+	if b1.String() != b2.String() {
+		t.Error("Dump() - different from the tree without an arena")
+	}
+	s1, s2 := trie.Stats(), plain.Stats()
+	if s1.Internals != s2.Internals || s1.Externals != s2.Externals || s1.KeyBytes != s2.KeyBytes || s1.MaxDepth != s2.MaxDepth {
+		t.Errorf("Stats() - invalid stats %+v, %+v", s1, s2)
+	}
+	for e := range critbitgo.Diff(trie, plain, nil) {
+		t.Errorf("Diff() - invalid event %v %x", e.Kind, e.Key)
+	}
+
+	data, err := trie.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() - error occurred %s", err)
+	}
+	decoded := critbitgo.NewTrieOf[[]byte](codec, critbitgo.WithArena())
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() - error occurred %s", err)
+	}
+	if err := decoded.Validate(); err != nil {
+		t.Fatalf("Validate() - %s", err)
+	}
+	if !reflect.DeepEqual(toMap(decoded), toMap(plain)) {
+		t.Error("UnmarshalBinary() - invalid elems")
+	}
+}
+
+// the tree in an arena is not scanned by the garbage collector, unlike a tree of pointers.
+func TestArenaScan(t *testing.T) {
+	keys := make([][]byte, 100000)
+	for i := range keys {
+		keys[i] = binary.BigEndian.AppendUint32(nil, uint32(i)*2654435761)
+	}
+	build := func(opts ...critbitgo.Option) *critbitgo.TrieOf[int] {
+		trie := critbitgo.NewTrieOf[int](opts...)
+		for i, key := range keys {
+			trie.Set(key, i)
+		}
+		return trie
+	}
+	// bytes of the heap scanned by the last GC
+	sample := []metrics.Sample{{Name: "/gc/scan/heap:bytes"}}
+	scanned := func() int64 {
+		runtime.GC()
+		metrics.Read(sample)
+		return int64(sample[0].Value.Uint64())
+	}
+
+	base := scanned()
+	trie := build(critbitgo.WithArena())
+	arena := scanned() - base
+	runtime.KeepAlive(trie)
+	trie = nil
+
+	base = scanned()
+	plain := build()
+	pointers := scanned() - base
+	runtime.KeepAlive(plain)
+
+	if pointers < int64(len(keys))*16 || arena > pointers/32 {
+		t.Errorf("scanned bytes - %d with an arena, %d without it", arena, pointers)
+	}
+}
+
+func TestArenaAllocs(t *testing.T) {
+	keys := make([][]byte, 10000)
+	for i := range keys {
+		keys[i] = binary.BigEndian.AppendUint32(nil, uint32(i)*2654435761)
+	}
+	var trie *critbitgo.TrieOf[int]
+	allocs := testing.AllocsPerRun(1, func() {
+		trie = critbitgo.NewTrieOf[int](critbitgo.WithArena())
+		for i, key := range keys {
+			trie.Set(key, i)
+		}
+	})
+	// slabs of nodes and chunks of keys, instead of 2 objects per key
+	if allocs > float64(len(keys))/100 {
+		t.Errorf("Set() - too many allocations [%v]", allocs)
+	}
+	if trie.Size() != len(keys) {
+		t.Errorf("Size() - invalid size [%d]", trie.Size())
+	}
+}
+
+func TestNetArena(t *testing.T) {
+	n := critbitgo.NewNetOf[int](critbitgo.WithArena())
+	routes := []string{"10.0.0.0/8", "10.1.0.0/16", "192.168.0.0/24", "2001:db8::/32"}
+	for i, s := range routes {
+		if err := n.AddPrefix(netip.MustParsePrefix(s), i); err != nil {
+			t.Fatalf("AddPrefix() - %s: error occurred %s", s, err)
+		}
+	}
+	if route, value, ok := n.MatchAddr(netip.MustParseAddr("10.1.2.3")); !ok || route.String() != "10.1.0.0/16" || value != 1 {
+		t.Errorf("MatchAddr() - invalid result %s, %d", route, value)
+	}
+	if route, value, ok := n.MatchAddr(netip.MustParseAddr("2001:db8::1")); !ok || route.String() != "2001:db8::/32" || value != 3 {
+		t.Errorf("MatchAddr() - invalid result %s, %d", route, value)
+	}
+	var matched []string
+	err := n.WalkMatchPrefix(netip.MustParsePrefix("10.1.2.0/24"), func(p netip.Prefix, _ int) bool {
+		matched = append(matched, p.String())
+		return true
+	})
+	if err != nil || !reflect.DeepEqual(matched, routes[:2]) {
+		t.Errorf("WalkMatchPrefix() - invalid routes %v, %v", matched, err)
+	}
+	if n.Size() != len(routes) {
+		t.Errorf("Size() - invalid size [%d]", n.Size())
+	}
+}
@@ -0,0 +1,233 @@
+package critbitgo
+
+import (
+	"errors"
+	"iter"
+	"net"
+)
+
+var (
+	// ErrUnsortedKeys is returned when keys are not in ascending order.
+	ErrUnsortedKeys = errors.New("critbitgo: keys are not sorted")
+	// ErrDuplicateKey is returned when a key appears twice.
+	ErrDuplicateKey = errors.New("critbitgo: duplicate key")
+
+	errLengthMismatch = errors.New("critbitgo: numbers of keys and values are different")
+)
+
+// builder builds a tree from sorted keys in linear time.
+// Since every key is larger than the previous one, a new key is always added
+// on the right spine of the tree, which is kept as a stack.
+type builder[V any] struct {
+	t     *TrieOf[V]
+	last  *external[V]
+	spine []*internal[V]
+
+	// the same for a tree with WithArena
+	arenaLast  uint32
+	arenaSpine []uint32
+}
+
+// starting to build into an empty tree.
+func newBuilder[V any](t *TrieOf[V]) *builder[V] {
+	t.Clear()
+	return &builder[V]{t: t}
+}
+
+// adding a key which is larger than all keys added so far.
+// `key` is stored as it is.
+func (b *builder[V]) add(key []byte, value V) error {
+	if b.t.opts.arena {
+		return b.addArena(key, value)
+	}
+
+	// an empty tree
+	if b.last == nil {
+		b.last = b.t.newExternal(key, value)
+		b.t.root.external = b.last
+		b.t.size = 1
+		return nil
+	}
+
+	newOffset, newBit, newCont := b.last.criticalBit(key)
+	if newOffset == -1 {
+		return ErrDuplicateKey
+	}
+	newNode := internal[V]{
+		critBit: critBit{offset: newOffset, bit: newBit, cont: newCont},
+		gen:     b.t.gen,
+	}
+	if newNode.direction(key) == 0 {
+		return ErrUnsortedKeys
+	}
+
+	// finding the position on the right spine
+	for len(b.spine) > 0 && !b.spine[len(b.spine)-1].above(&newNode.critBit) {
+		b.spine = b.spine[:len(b.spine)-1]
+	}
+	wherep := &b.t.root
+	if len(b.spine) > 0 {
+		wherep = &b.spine[len(b.spine)-1].child[1]
+	}
+
+	in := b.t.newInternal(newNode)
+	b.last = b.t.newExternal(key, value)
+	in.child[0] = *wherep
+	in.child[1].external = b.last
+	*wherep = node[V]{internal: in}
+	b.spine = append(b.spine, in)
+	b.t.size += 1
+	return nil
+}
+
+// finishing to build.
+func (b *builder[V]) finish() {
+	if !b.t.opts.counted {
+		return
+	}
+	if a := b.t.arena; a != nil {
+		a.countNode(a.root)
+	} else if b.t.root.internal != nil {
+		countNode(&b.t.root)
+	}
+}
+
+// filling the number of keys in each subtree.
+func countNode[V any](n *node[V]) int {
+	if in := n.internal; in != nil {
+		c := in.counted()
+		c.count = countNode(&in.child[0]) + countNode(&in.child[1])
+		return c.count
+	}
+	return 1
+}
+
+// create a tree from keys sorted in ascending order in linear time.
+// If `values` is nil, values are nil. Otherwise it must have the same length as `keys`.
+// If keys are not sorted or contain duplicates, returns ErrUnsortedKeys or ErrDuplicateKey.
+func NewTrieFromSorted(keys [][]byte, values []interface{}, opts ...Option) (*Trie, error) {
+	return NewTrieOfFromSorted(keys, values, opts...)
+}
+
+// create a tree holding values of type V from keys sorted in ascending order in linear time.
+// The arguments are handled in the same way as NewTrieFromSorted.
+func NewTrieOfFromSorted[V any](keys [][]byte, values []V, opts ...Option) (*TrieOf[V], error) {
+	if values != nil && len(values) != len(keys) {
+		return nil, errLengthMismatch
+	}
+	return NewTrieOfFromSortedSeq(func(yield func([]byte, V) bool) {
+		var zero V
+		for i, key := range keys {
+			if values != nil {
+				zero = values[i]
+			}
+			if !yield(key, zero) {
+				return
+			}
+		}
+	}, opts...)
+}
+
+// create a tree holding values of type V from an iterator over keys sorted in ascending order
+// in linear time. Keys are stored in the same way as Insert.
+// If keys are not sorted or contain duplicates, returns ErrUnsortedKeys or ErrDuplicateKey.
+func NewTrieOfFromSortedSeq[V any](seq iter.Seq2[[]byte, V], opts ...Option) (t *TrieOf[V], err error) {
+	t = NewTrieOf[V](opts...)
+	b := newBuilder(t)
+	for k, v := range seq {
+		if err = b.add(t.storedKey(k), v); err != nil {
+			return nil, err
+		}
+	}
+	b.finish()
+	return
+}
+
+// Create a SortedMap from keys sorted in ascending order in linear time.
+// The arguments are handled in the same way as NewTrieFromSorted.
+func NewSortedMapFromSorted(keys []string, values []interface{}, opts ...Option) (*SortedMap, error) {
+	return NewSortedMapOfFromSorted(keys, values, opts...)
+}
+
+// Create a SortedMap holding values of type V from keys sorted in ascending order in linear time.
+// The arguments are handled in the same way as NewTrieFromSorted.
+func NewSortedMapOfFromSorted[V any](keys []string, values []V, opts ...Option) (*SortedMapOf[V], error) {
+	if values != nil && len(values) != len(keys) {
+		return nil, errLengthMismatch
+	}
+	return NewSortedMapOfFromSortedSeq(func(yield func(string, V) bool) {
+		var zero V
+		for i, key := range keys {
+			if values != nil {
+				zero = values[i]
+			}
+			if !yield(key, zero) {
+				return
+			}
+		}
+	}, opts...)
+}
+
+// Create a SortedMap holding values of type V from an iterator over keys sorted
+// in ascending order in linear time.
+// If keys are not sorted or contain duplicates, returns ErrUnsortedKeys or ErrDuplicateKey.
+func NewSortedMapOfFromSortedSeq[V any](seq iter.Seq2[string, V], opts ...Option) (*SortedMapOf[V], error) {
+	t, err := NewTrieOfFromSortedSeq(func(yield func([]byte, V) bool) {
+		for k, v := range seq {
+			if !yield([]byte(k), v) {
+				return
+			}
+		}
+	}, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &SortedMapOf[V]{t}, nil
+}
+
+// Create IP routing table from routes in CIDR notation sorted in the order of Walk in linear time.
+// If `values` is nil, values are nil. Otherwise it must have the same length as `cidrs`.
+// If a route is not CIDR notation, returns an error. If routes are not sorted or contain
+// duplicates, returns ErrUnsortedKeys or ErrDuplicateKey.
+func NewNetFromSorted(cidrs []string, values []interface{}, opts ...Option) (*Net, error) {
+	return NewNetOfFromSorted(cidrs, values, opts...)
+}
+
+// Create IP routing table holding values of type V from routes in CIDR notation sorted
+// in the order of Walk in linear time.
+// The arguments are handled in the same way as NewNetFromSorted.
+func NewNetOfFromSorted[V any](cidrs []string, values []V, opts ...Option) (n *NetOf[V], err error) {
+	if values != nil && len(values) != len(cidrs) {
+		return nil, errLengthMismatch
+	}
+	n = NewNetOf[V](opts...)
+	builders := [2]*builder[V]{newBuilder(n.tries[IPv4]), newBuilder(n.tries[IPv6])}
+	var last int
+	for i, s := range cidrs {
+		var key []byte
+		var r *net.IPNet
+		if r, err = netParseCIDR(s); err != nil {
+			return nil, err
+		}
+		if key, err = n.ipNetKey(r, true); err != nil {
+			return nil, err
+		}
+		// IPv4 routes precede IPv6 routes
+		family := netKeyFamily(key)
+		if family < last {
+			return nil, ErrUnsortedKeys
+		}
+		last = family
+		var value V
+		if values != nil {
+			value = values[i]
+		}
+		if err = builders[family].add(n.tries[family].storedKey(key), value); err != nil {
+			return nil, err
+		}
+	}
+	for _, b := range builders {
+		b.finish()
+	}
+	return
+}
@@ -0,0 +1,324 @@
+package critbitgo
+
+import (
+	"net"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+)
+
+// Crit-bit Tree safe for concurrent use, holding values of type V.
+// Readers never block: each read works on an immutable version of the tree
+// which is published atomically. Writers are serialized and publish a new version
+// sharing unchanged nodes with the previous one.
+// It must be created by NewConcurrentTrieOf.
+type ConcurrentTrieOf[V any] struct {
+	mu   sync.Mutex
+	root atomic.Pointer[PersistentTrieOf[V]]
+}
+
+// Crit-bit Tree safe for concurrent use, holding values of any type.
+type ConcurrentTrie = ConcurrentTrieOf[interface{}]
+
+// update the tree, publishing the version returned by `f`.
+func (t *ConcurrentTrieOf[V]) update(f func(*PersistentTrieOf[V]) *PersistentTrieOf[V]) {
+	t.mu.Lock()
+	t.root.Store(f(t.root.Load()))
+	t.mu.Unlock()
+}
+
+// insert into the tree.
+// if `key` is alredy in Trie, return false.
+func (t *ConcurrentTrieOf[V]) Insert(key []byte, value V) (ok bool) {
+	t.update(func(p *PersistentTrieOf[V]) (np *PersistentTrieOf[V]) {
+		np, ok = p.Insert(key, value)
+		return
+	})
+	return
+}
+
+// set into the tree.
+func (t *ConcurrentTrieOf[V]) Set(key []byte, value V) {
+	t.update(func(p *PersistentTrieOf[V]) *PersistentTrieOf[V] {
+		return p.Set(key, value)
+	})
+}
+
+// deleting elements.
+// if `key` is in Trie, `ok` is true.
+func (t *ConcurrentTrieOf[V]) Delete(key []byte) (value V, ok bool) {
+	t.update(func(p *PersistentTrieOf[V]) (np *PersistentTrieOf[V]) {
+		np, value, ok = p.Delete(key)
+		return
+	})
+	return
+}
+
+// clearing a tree.
+func (t *ConcurrentTrieOf[V]) Clear() {
+	t.update(func(p *PersistentTrieOf[V]) *PersistentTrieOf[V] {
+		return p.Clear()
+	})
+}
+
+// Returns the current version of the tree.
+// It is not affected by later updates, and is safe to read while the tree is updated.
+func (t *ConcurrentTrieOf[V]) Snapshot() *PersistentTrieOf[V] {
+	return t.root.Load()
+}
+
+// membership testing.
+func (t *ConcurrentTrieOf[V]) Contains(key []byte) bool {
+	return t.root.Load().Contains(key)
+}
+
+// get member.
+// if `key` is in Trie, `ok` is true.
+func (t *ConcurrentTrieOf[V]) Get(key []byte) (value V, ok bool) {
+	return t.root.Load().Get(key)
+}
+
+// return the number of key in a tree.
+func (t *ConcurrentTrieOf[V]) Size() int {
+	return t.root.Load().Size()
+}
+
+// Search for the longest matching key from the beginning of the given key.
+// if `key` is in Trie, `ok` is true.
+func (t *ConcurrentTrieOf[V]) LongestPrefix(given []byte) (key []byte, value V, ok bool) {
+	return t.root.Load().LongestPrefix(given)
+}
+
+// fetching elements with a given prefix from the current version.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *ConcurrentTrieOf[V]) Allprefixed(prefix []byte, handle func(key []byte, value V) bool) bool {
+	return t.root.Load().Allprefixed(prefix, handle)
+}
+
+// Iterating elements of the current version from the first key that is equal to or greater than a given start key.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *ConcurrentTrieOf[V]) WalkFrom(start []byte, handle func(key []byte, value V) bool) bool {
+	return t.root.Load().WalkFrom(start, handle)
+}
+
+// create a tree safe for concurrent use.
+func NewConcurrentTrie(opts ...Option) *ConcurrentTrie {
+	return NewConcurrentTrieOf[interface{}](opts...)
+}
+
+// create a tree safe for concurrent use, holding values of type V.
+func NewConcurrentTrieOf[V any](opts ...Option) *ConcurrentTrieOf[V] {
+	t := &ConcurrentTrieOf[V]{}
+	t.root.Store(NewPersistentTrieOf[V](opts...))
+	return t
+}
+
+// IP routing table safe for concurrent use, holding values of type V.
+// Lookups never block in the same way as ConcurrentTrieOf.
+// It must be created by NewConcurrentNetOf.
+type ConcurrentNetOf[V any] struct {
+	mu   sync.Mutex
+	root atomic.Pointer[NetOf[V]] // never modified once published
+}
+
+// IP routing table safe for concurrent use, holding values of any type.
+type ConcurrentNet = ConcurrentNetOf[interface{}]
+
+// update the table of the family of `key`, publishing the version with the table returned by `f`.
+func (n *ConcurrentNetOf[V]) update(key []byte, f func(*PersistentTrieOf[V]) *PersistentTrieOf[V]) {
+	n.mu.Lock()
+	nn := *n.root.Load()
+	family := netKeyFamily(key)
+	p := f(&PersistentTrieOf[V]{*nn.tries[family]})
+	nn.tries[family] = &p.trie
+	n.root.Store(&nn)
+	n.mu.Unlock()
+}
+
+// Add a route.
+// If `r` is not IPv4/IPv6 network, returns an error.
+func (n *ConcurrentNetOf[V]) Add(r *net.IPNet, value V) (err error) {
+	var key []byte
+	if key, err = n.root.Load().ipNetKey(r, true); err == nil {
+		n.update(key, func(p *PersistentTrieOf[V]) *PersistentTrieOf[V] {
+			return p.Set(key, value)
+		})
+	}
+	return
+}
+
+// Add a route.
+// If `s` is not CIDR notation, returns an error.
+func (n *ConcurrentNetOf[V]) AddCIDR(s string, value V) (err error) {
+	var r *net.IPNet
+	if r, err = netParseCIDR(s); err == nil {
+		err = n.Add(r, value)
+	}
+	return
+}
+
+// Delete a specific route.
+// If `r` is not IP4/IPv6 network or a route is not found, `ok` is false.
+func (n *ConcurrentNetOf[V]) Delete(r *net.IPNet) (value V, ok bool, err error) {
+	var key []byte
+	if key, err = n.root.Load().ipNetKey(r, true); err == nil {
+		n.update(key, func(p *PersistentTrieOf[V]) (np *PersistentTrieOf[V]) {
+			np, value, ok = p.Delete(key)
+			return
+		})
+	}
+	return
+}
+
+// Delete a specific route.
+// If `s` is not CIDR notation or a route is not found, `ok` is false.
+func (n *ConcurrentNetOf[V]) DeleteCIDR(s string) (value V, ok bool, err error) {
+	var r *net.IPNet
+	if r, err = netParseCIDR(s); err == nil {
+		value, ok, err = n.Delete(r)
+	}
+	return
+}
+
+// Deletes all routes.
+func (n *ConcurrentNetOf[V]) Clear() {
+	n.mu.Lock()
+	nn := *n.root.Load()
+	for family, t := range nn.tries {
+		p := (&PersistentTrieOf[V]{*t}).Clear()
+		nn.tries[family] = &p.trie
+	}
+	n.root.Store(&nn)
+	n.mu.Unlock()
+}
+
+// Get a specific route.
+// If `r` is not IPv4/IPv6 network or a route is not found, `ok` is false.
+func (n *ConcurrentNetOf[V]) Get(r *net.IPNet) (value V, ok bool, err error) {
+	return n.root.Load().Get(r)
+}
+
+// Get a specific route.
+// If `s` is not CIDR notation or a route is not found, `ok` is false.
+func (n *ConcurrentNetOf[V]) GetCIDR(s string) (value V, ok bool, err error) {
+	return n.root.Load().GetCIDR(s)
+}
+
+// Return a specific route by using the longest prefix matching.
+// If `r` is not IPv4/IPv6 network or a route is not found, `route` is nil.
+func (n *ConcurrentNetOf[V]) Match(r *net.IPNet) (route *net.IPNet, value V, err error) {
+	return n.root.Load().Match(r)
+}
+
+// Return a specific route by using the longest prefix matching.
+// If `s` is not CIDR notation, or a route is not found, `route` is nil.
+func (n *ConcurrentNetOf[V]) MatchCIDR(s string) (route *net.IPNet, value V, err error) {
+	return n.root.Load().MatchCIDR(s)
+}
+
+// Return a specific route by using the longest prefix matching.
+// If `ip` is invalid IP, or a route is not found, `route` is nil.
+func (n *ConcurrentNetOf[V]) MatchIP(ip net.IP) (route *net.IPNet, value V, err error) {
+	return n.root.Load().MatchIP(ip)
+}
+
+// Return a bool indicating whether a route would be found
+func (n *ConcurrentNetOf[V]) ContainedIP(ip net.IP) (contained bool, err error) {
+	return n.root.Load().ContainedIP(ip)
+}
+
+// Returns number of routes.
+func (n *ConcurrentNetOf[V]) Size() int {
+	return n.root.Load().Size()
+}
+
+// Returns number of routes of a given family.
+// If `f` is neither IPv4 nor IPv6, returns 0.
+func (n *ConcurrentNetOf[V]) FamilySize(f Family) int {
+	return n.root.Load().FamilySize(f)
+}
+
+// Walk iterates routes of the current version from a given route.
+// If `r` is not IPv4/IPv6 network or not in the table, returns an error.
+// handle is called with arguments route and value (if handle returns `false`, the iteration is aborted)
+func (n *ConcurrentNetOf[V]) Walk(r *net.IPNet, handle func(*net.IPNet, V) bool) error {
+	return n.root.Load().Walk(r, handle)
+}
+
+// WalkFamily iterates routes of the current version of a given family.
+// If `f` is neither IPv4 nor IPv6, returns an error wrapping ErrInvalidFamily.
+// handle is called with arguments route and value (if handle returns `false`, the iteration is aborted)
+func (n *ConcurrentNetOf[V]) WalkFamily(f Family, handle func(*net.IPNet, V) bool) error {
+	return n.root.Load().WalkFamily(f, handle)
+}
+
+// WalkPrefix interates routes of the current version that have a given prefix.
+// If `r` is not IPv4/IPv6 network, returns an error.
+// handle is called with arguments route and value (if handle returns `false`, the iteration is aborted)
+func (n *ConcurrentNetOf[V]) WalkPrefix(r *net.IPNet, handle func(*net.IPNet, V) bool) error {
+	return n.root.Load().WalkPrefix(r, handle)
+}
+
+// WalkMatch interates routes of the current version that match a given route.
+// If `r` is not IPv4/IPv6 network, returns an error.
+// handle is called with arguments route and value (if handle returns `false`, the iteration is aborted)
+func (n *ConcurrentNetOf[V]) WalkMatch(r *net.IPNet, handle func(*net.IPNet, V) bool) error {
+	return n.root.Load().WalkMatch(r, handle)
+}
+
+// Add a route.
+// If `p` is not a valid prefix, returns an error.
+func (n *ConcurrentNetOf[V]) AddPrefix(p netip.Prefix, value V) error {
+	key, err := n.root.Load().prefixKey(nil, p, true)
+	if err != nil {
+		return err
+	}
+	n.update(key, func(t *PersistentTrieOf[V]) *PersistentTrieOf[V] {
+		return t.Set(key, value)
+	})
+	return nil
+}
+
+// Delete a specific route.
+// If `p` is not a valid prefix or a route is not found, `ok` is false.
+func (n *ConcurrentNetOf[V]) DeletePrefix(p netip.Prefix) (value V, ok bool, err error) {
+	var key []byte
+	if key, err = n.root.Load().prefixKey(nil, p, true); err == nil {
+		n.update(key, func(t *PersistentTrieOf[V]) (nt *PersistentTrieOf[V]) {
+			nt, value, ok = t.Delete(key)
+			return
+		})
+	}
+	return
+}
+
+// Get a specific route.
+// If `p` is not a valid prefix or a route is not found, `ok` is false.
+func (n *ConcurrentNetOf[V]) GetPrefix(p netip.Prefix) (value V, ok bool, err error) {
+	return n.root.Load().GetPrefix(p)
+}
+
+// Return a specific route by using the longest prefix matching.
+// If `p` is not a valid prefix, returns an error.
+// If a route is not found, `ok` is false.
+func (n *ConcurrentNetOf[V]) MatchPrefix(p netip.Prefix) (route netip.Prefix, value V, ok bool, err error) {
+	return n.root.Load().MatchPrefix(p)
+}
+
+// Return a specific route by using the longest prefix matching.
+// If `addr` is not a valid address or a route is not found, `ok` is false.
+func (n *ConcurrentNetOf[V]) MatchAddr(addr netip.Addr) (route netip.Prefix, value V, ok bool) {
+	return n.root.Load().MatchAddr(addr)
+}
+
+// Create IP routing table safe for concurrent use.
+func NewConcurrentNet(opts ...Option) *ConcurrentNet {
+	return NewConcurrentNetOf[interface{}](opts...)
+}
+
+// Create IP routing table safe for concurrent use, holding values of type V.
+func NewConcurrentNetOf[V any](opts ...Option) *ConcurrentNetOf[V] {
+	n := &ConcurrentNetOf[V]{}
+	n.root.Store(NewNetOf[V](opts...))
+	return n
+}
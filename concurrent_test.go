@@ -0,0 +1,185 @@
+package critbitgo_test
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sync"
+	"testing"
+
+	"github.com/k-sone/critbitgo"
+)
+
+func TestConcurrentTrie(t *testing.T) {
+	trie := critbitgo.NewConcurrentTrie()
+	if !trie.Insert([]byte("a"), "a") {
+		t.Error("Insert() - failed insert")
+	}
+	if trie.Insert([]byte("a"), "a") {
+		t.Error("Insert() - check exists")
+	}
+	snapshot := trie.Snapshot()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				key := []byte(fmt.Sprintf("%d-%d", i, j))
+				trie.Set(key, j)
+				if j%2 == 0 {
+					trie.Delete(key)
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if v, ok := trie.Get([]byte("a")); !ok || v != "a" {
+					t.Errorf("Get() - not found [%v]", v)
+				}
+				trie.LongestPrefix([]byte("0-1"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s := trie.Size(); s != 4*50+1 {
+		t.Errorf("Size() - expected [%d], actual [%d]", 4*50+1, s)
+	}
+	if s := snapshot.Size(); s != 1 {
+		t.Errorf("Snapshot() - changed [%d]", s)
+	}
+	if v, ok := trie.Delete([]byte("a")); !ok || v != "a" {
+		t.Errorf("Delete() - failed [%v]", v)
+	}
+	trie.Clear()
+	if s := trie.Size(); s != 0 {
+		t.Errorf("Clear() - failed [%d]", s)
+	}
+}
+
+func TestConcurrentNet(t *testing.T) {
+	trie := critbitgo.NewConcurrentNet()
+	if err := trie.AddCIDR("10.0.0.0/8", "10.0.0.0/8"); err != nil {
+		t.Errorf("AddCIDR() - error occurred %s", err)
+	}
+	if err := trie.AddCIDR("", nil); err == nil {
+		t.Error("AddCIDR() - not error")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				cidr := fmt.Sprintf("10.%d.%d.0/24", i, j)
+				trie.AddCIDR(cidr, cidr)
+				if j%2 == 0 {
+					trie.DeleteCIDR(cidr)
+				}
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				if r, _, err := trie.MatchIP(net.IPv4(10, 9, 0, 1)); r == nil || r.String() != "10.0.0.0/8" || err != nil {
+					t.Errorf("MatchIP() - failed: %v, %v", r, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if s := trie.Size(); s != 4*50+1 {
+		t.Errorf("Size() - expected [%d], actual [%d]", 4*50+1, s)
+	}
+	if r, v, err := trie.MatchCIDR("10.1.1.1/32"); r == nil || v != "10.1.1.0/24" || err != nil {
+		t.Errorf("MatchCIDR() - failed: %v, %v, %v", r, v, err)
+	}
+	if v, ok, err := trie.DeleteCIDR("10.0.0.0/8"); v != "10.0.0.0/8" || !ok || err != nil {
+		t.Errorf("DeleteCIDR() - failed: %v, %v, %v", v, ok, err)
+	}
+	if b, err := trie.ContainedIP(net.IPv4(10, 9, 0, 1)); b || err != nil {
+		t.Errorf("ContainedIP() - phantom: %v, %v", b, err)
+	}
+	if v, ok, err := trie.GetPrefix(netip.MustParsePrefix("10.1.1.0/24")); v != "10.1.1.0/24" || !ok || err != nil {
+		t.Errorf("GetPrefix() - failed: %v, %v, %v", v, ok, err)
+	}
+	if v, ok, err := trie.DeletePrefix(netip.MustParsePrefix("10.1.1.0/24")); v != "10.1.1.0/24" || !ok || err != nil {
+		t.Errorf("DeletePrefix() - failed: %v, %v, %v", v, ok, err)
+	}
+	if _, ok, err := trie.DeletePrefix(netip.Prefix{}); ok || !errors.Is(err, critbitgo.ErrInvalidIP) {
+		t.Errorf("DeletePrefix() - unexpected error %v", err)
+	}
+}
+
+func TestTrieSnapshotConcurrent(t *testing.T) {
+	trie := critbitgo.NewTrie()
+	for i := 0; i < 100; i++ {
+		trie.Set([]byte(fmt.Sprint(i)), i)
+	}
+	snapshot := trie.Snapshot()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			trie.Set([]byte(fmt.Sprint(i)), -i)
+			trie.Delete([]byte(fmt.Sprint(i + 50)))
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		var c int
+		snapshot.Allprefixed(nil, func(k []byte, v interface{}) bool {
+			if string(k) != fmt.Sprint(v) {
+				t.Errorf("Snapshot() - changed [%s](%v)", k, v)
+			}
+			c++
+			return true
+		})
+		if c != 100 {
+			t.Errorf("Snapshot() - invalid size [%d]", c)
+		}
+	}
+	wg.Wait()
+}
+
+func TestSetOperationsConcurrent(t *testing.T) {
+	a, b := critbitgo.NewTrieOf[int](), critbitgo.NewTrieOf[int]()
+	for i := 0; i < 100; i++ {
+		a.Set([]byte(fmt.Sprint(i)), i)
+		b.Set([]byte(fmt.Sprint(i+50)), i)
+	}
+
+	// set operations only read the operands
+	ops := []func() *critbitgo.TrieOf[int]{
+		func() *critbitgo.TrieOf[int] { return a.Union(b, nil) },
+		func() *critbitgo.TrieOf[int] { return b.Union(a, nil) },
+		func() *critbitgo.TrieOf[int] { return a.Intersect(b, nil) },
+		func() *critbitgo.TrieOf[int] { return b.Difference(a) },
+		func() *critbitgo.TrieOf[int] { return a.SymmetricDifference(b) },
+	}
+	sizes := []int{150, 150, 50, 50, 100}
+	var wg sync.WaitGroup
+	for i, op := range ops {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if s := op().Size(); s != sizes[i] {
+				t.Errorf("set operation %d - invalid size [%d]", i, s)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if v, ok := a.Get([]byte(fmt.Sprint(i))); !ok || v != i {
+				t.Errorf("Get() - invalid value [%d]", v)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -7,6 +7,8 @@ import (
 	"io"
 	"os"
 	"strconv"
+	"sync/atomic"
+	"unsafe"
 )
 
 // The matrix of most significant bit
@@ -22,26 +24,102 @@ func buildMsbMatrix() {
 	}
 }
 
-type node struct {
-	internal *internal
-	external *external
+type node[V any] struct {
+	internal *internal[V]
+	external *external[V]
 }
 
-type internal struct {
-	child  [2]node
+type internal[V any] struct {
+	child [2]node[V]
+	critBit
+	gen uint64 // generation of the tree owning the node
+}
+
+// the critical bit tested by an internal node.
+type critBit struct {
 	offset int
 	bit    byte
 	cont   bool // if true, key of child[1] contains key of child[0]
 }
 
-type external struct {
+// internal node of a tree with WithOrderStatistics.
+// It is allocated in place of internal, so that trees without the option do not pay for the count.
+type countedInternal[V any] struct {
+	internal[V]
+	count int // number of keys in the subtree
+}
+
+// returning the node as countedInternal, which it must have been allocated as.
+func (n *internal[V]) counted() *countedInternal[V] {
+	return (*countedInternal[V])(unsafe.Pointer(n))
+}
+
+// allocating an internal node, as countedInternal if `counted`.
+func newInternal[V any](in internal[V], counted bool) *internal[V] {
+	if counted {
+		return &(&countedInternal[V]{internal: in}).internal
+	}
+	// not `&in`, which would move `in` to the heap in both cases
+	p := new(internal[V])
+	*p = in
+	return p
+}
+
+// returning a copy of an internal node, including the count if `counted`.
+func copyInternal[V any](in *internal[V], counted bool) *internal[V] {
+	if counted {
+		c := *in.counted()
+		return &c.internal
+	}
+	c := *in
+	return &c
+}
+
+// allocating an internal node of the tree.
+func (t *TrieOf[V]) newInternal(in internal[V]) *internal[V] {
+	return newInternal(in, t.opts.counted)
+}
+
+// allocating an external node of the tree.
+func (t *TrieOf[V]) newExternal(key []byte, value V) *external[V] {
+	return &external[V]{key: key, value: value}
+}
+
+// returning a copy of an internal node of the tree.
+func (t *TrieOf[V]) copyInternal(in *internal[V]) *internal[V] {
+	return copyInternal(in, t.opts.counted)
+}
+
+// returning the key to store for a new key, copying it with WithCopyKeys.
+// With WithArena, the key is copied into the arena instead.
+func (t *TrieOf[V]) storedKey(key []byte) []byte {
+	if t.opts.arena {
+		return key
+	}
+	return t.opts.storedKey(key)
+}
+
+// returning a copy of a key to store, unless it is copied into the arena with WithArena.
+func (t *TrieOf[V]) copyKey(key []byte) []byte {
+	if t.opts.arena {
+		return key
+	}
+	return bytes.Clone(key)
+}
+
+type external[V any] struct {
 	key   []byte
-	value interface{}
+	value V
 }
 
 // finding the critical bit.
-func (n *external) criticalBit(key []byte) (offset int, bit byte, cont bool) {
-	nlen := len(n.key)
+func (n *external[V]) criticalBit(key []byte) (offset int, bit byte, cont bool) {
+	return criticalBit(n.key, key)
+}
+
+// finding the critical bit between a stored key `nkey` and `key`.
+func criticalBit(nkey, key []byte) (offset int, bit byte, cont bool) {
+	nlen := len(nkey)
 	klen := len(key)
 	mlen := nlen
 	if nlen > klen {
@@ -50,7 +128,7 @@ func (n *external) criticalBit(key []byte) (offset int, bit byte, cont bool) {
 
 	// find first differing byte and bit
 	for offset = 0; offset < mlen; offset++ {
-		if a, b := key[offset], n.key[offset]; a != b {
+		if a, b := key[offset], nkey[offset]; a != b {
 			bit = msbMatrix[a^b]
 			return
 		}
@@ -59,7 +137,7 @@ func (n *external) criticalBit(key []byte) (offset int, bit byte, cont bool) {
 	if nlen < klen {
 		bit = msbMatrix[key[offset]]
 	} else if nlen > klen {
-		bit = msbMatrix[n.key[offset]]
+		bit = msbMatrix[nkey[offset]]
 	} else {
 		// two keys are equal
 		offset = -1
@@ -68,21 +146,35 @@ func (n *external) criticalBit(key []byte) (offset int, bit byte, cont bool) {
 }
 
 // calculate direction.
-func (n *internal) direction(key []byte) int {
+func (n *critBit) direction(key []byte) int {
 	if n.offset < len(key) && (key[n.offset]&n.bit != 0 || n.cont) {
 		return 1
 	}
 	return 0
 }
 
-// Crit-bit Tree
-type Trie struct {
-	root node
-	size int
+// Crit-bit Tree holding values of type V.
+// It is not safe for concurrent use, see ConcurrentTrieOf.
+//
+// Keys given to Insert and Set are stored without copying unless WithCopyKeys is given,
+// so they must not be modified afterwards. Keys returned by methods and passed to handle functions
+// are the stored keys, which are never modified by the tree: they may be retained, but must not be modified.
+type TrieOf[V any] struct {
+	root  node[V]
+	size  int
+	opts  options
+	gen   uint64    // nodes of other generations may be shared with snapshots and results of set operations
+	arena *arena[V] // nodes of a non-empty tree with WithArena, instead of root
 }
 
+// The last generation assigned by Snapshot.
+var generation atomic.Uint64
+
+// Crit-bit Tree holding values of any type.
+type Trie = TrieOf[interface{}]
+
 // searching the tree.
-func (t *Trie) search(key []byte) *node {
+func (t *TrieOf[V]) search(key []byte) *node[V] {
 	n := &t.root
 	for n.internal != nil {
 		n = &n.internal.child[n.internal.direction(key)]
@@ -91,7 +183,10 @@ func (t *Trie) search(key []byte) *node {
 }
 
 // membership testing.
-func (t *Trie) Contains(key []byte) bool {
+func (t *TrieOf[V]) Contains(key []byte) bool {
+	if a := t.arena; a != nil {
+		return bytes.Equal(a.key(a.search(key)), key)
+	}
 	if n := t.search(key); n.external != nil && bytes.Equal(n.external.key, key) {
 		return true
 	}
@@ -100,7 +195,13 @@ func (t *Trie) Contains(key []byte) bool {
 
 // get member.
 // if `key` is in Trie, `ok` is true.
-func (t *Trie) Get(key []byte) (value interface{}, ok bool) {
+func (t *TrieOf[V]) Get(key []byte) (value V, ok bool) {
+	if a := t.arena; a != nil {
+		if ref := a.search(key); bytes.Equal(a.key(ref), key) {
+			return a.value(ref), true
+		}
+		return
+	}
 	if n := t.search(key); n.external != nil && bytes.Equal(n.external.key, key) {
 		return n.external.value, true
 	}
@@ -108,13 +209,14 @@ func (t *Trie) Get(key []byte) (value interface{}, ok bool) {
 }
 
 // insert into the tree (replaceable).
-func (t *Trie) insert(key []byte, value interface{}, replace bool) bool {
+func (t *TrieOf[V]) insert(key []byte, value V, replace bool) bool {
+	if t.opts.arena {
+		return t.arenaInsert(key, value, replace)
+	}
+
 	// an empty tree
 	if t.size == 0 {
-		t.root.external = &external{
-			key:   key,
-			value: value,
-		}
+		t.root.external = t.newExternal(t.storedKey(key), value)
 		t.size = 1
 		return true
 	}
@@ -125,66 +227,80 @@ func (t *Trie) insert(key []byte, value interface{}, replace bool) bool {
 	// already exists in the tree
 	if newOffset == -1 {
 		if replace {
-			n.external.value = value
+			if t.gen != 0 {
+				// the external node may be shared with snapshots
+				n = t.ownPath(key)
+				n.external = t.newExternal(n.external.key, value)
+			} else {
+				n.external.value = value
+			}
 			return true
 		}
 		return false
 	}
 
 	// allocate new node
-	newNode := &internal{
-		offset: newOffset,
-		bit:    newBit,
-		cont:   newCont,
-	}
+	newNode := t.newInternal(internal[V]{
+		critBit: critBit{offset: newOffset, bit: newBit, cont: newCont},
+		gen:     t.gen,
+	})
+	t.link(newNode, node[V]{external: t.newExternal(t.storedKey(key), value)}, key, 1)
+	t.size += 1
+	return true
+}
+
+// linking a subtree `n` of `size` keys into the tree at the position of `newNode`,
+// where `key` is one of the keys and all keys of `n` differ from the tree at the critical bit of `newNode`.
+func (t *TrieOf[V]) link(newNode *internal[V], n node[V], key []byte, size int) {
 	direction := newNode.direction(key)
-	newNode.child[direction].external = &external{
-		key:   key,
-		value: value,
-	}
+	newNode.child[direction] = n
 
 	// insert new node
 	wherep := &t.root
 	for in := wherep.internal; in != nil; in = wherep.internal {
-		if in.offset > newOffset || (in.offset == newOffset && in.bit < newBit) {
+		if !in.above(&newNode.critBit) {
 			break
 		}
+		in = t.own(wherep)
+		if t.opts.counted {
+			in.counted().count += size
+		}
 		wherep = &in.child[in.direction(key)]
 	}
-
-	if wherep.internal != nil {
-		newNode.child[1-direction].internal = wherep.internal
-	} else {
-		newNode.child[1-direction].external = wherep.external
-		wherep.external = nil
+	if t.opts.counted {
+		newNode.counted().count = wherep.count() + size
 	}
-	wherep.internal = newNode
-	t.size += 1
-	return true
+
+	newNode.child[1-direction] = *wherep
+	*wherep = node[V]{internal: newNode}
 }
 
 // insert into the tree.
 // if `key` is alredy in Trie, return false.
-func (t *Trie) Insert(key []byte, value interface{}) bool {
+func (t *TrieOf[V]) Insert(key []byte, value V) bool {
 	return t.insert(key, value, false)
 }
 
 // set into the tree.
-func (t *Trie) Set(key []byte, value interface{}) {
+func (t *TrieOf[V]) Set(key []byte, value V) {
 	t.insert(key, value, true)
 }
 
 // deleting elements.
 // if `key` is in Trie, `ok` is true.
-func (t *Trie) Delete(key []byte) (value interface{}, ok bool) {
+func (t *TrieOf[V]) Delete(key []byte) (value V, ok bool) {
+	if t.arena != nil {
+		return t.arenaDelete(key)
+	}
+
 	// an empty tree
 	if t.size == 0 {
 		return
 	}
 
 	var direction int
-	var whereq *node // pointer to the grandparent
-	var wherep *node = &t.root
+	var whereq *node[V] // pointer to the grandparent
+	var wherep *node[V] = &t.root
 
 	// finding the best candidate to delete
 	for in := wherep.internal; in != nil; in = wherep.internal {
@@ -204,6 +320,9 @@ func (t *Trie) Delete(key []byte) (value interface{}, ok bool) {
 	if whereq == nil {
 		wherep.external = nil
 	} else {
+		if t.gen != 0 || t.opts.counted {
+			whereq = t.parentPath(key)
+		}
 		othern := whereq.internal.child[1-direction]
 		whereq.internal = othern.internal
 		whereq.external = othern.external
@@ -212,28 +331,148 @@ func (t *Trie) Delete(key []byte) (value interface{}, ok bool) {
 	return
 }
 
+// copying shared nodes on the path to `key`, then returning the external node of `key`.
+func (t *TrieOf[V]) ownPath(key []byte) *node[V] {
+	p := &t.root
+	for p.internal != nil {
+		in := t.own(p)
+		p = &in.child[in.direction(key)]
+	}
+	return p
+}
+
+// copying shared nodes and updating counts on the path to `key` for deleting,
+// then returning the parent node of `key`.
+func (t *TrieOf[V]) parentPath(key []byte) *node[V] {
+	p := &t.root
+	for {
+		direction := p.internal.direction(key)
+		if p.internal.child[direction].internal == nil {
+			return p
+		}
+		in := t.own(p)
+		if t.opts.counted {
+			in.counted().count -= 1
+		}
+		p = &in.child[direction]
+	}
+}
+
+// returning the internal node of `p`, copying it if it is shared with snapshots.
+func (t *TrieOf[V]) own(p *node[V]) *internal[V] {
+	if in := p.internal; in.gen != t.gen {
+		c := t.copyInternal(in)
+		c.gen = t.gen
+		p.internal = c
+	}
+	return p.internal
+}
+
+// returning a deep copy of a tree.
+// keys and values are shared with the original, except that keys are copied with WithArena.
+func (t *TrieOf[V]) Clone() *TrieOf[V] {
+	c := &TrieOf[V]{size: t.size, opts: t.opts}
+	if t.arena != nil {
+		c.arena = t.arena.rebuild(t.size)
+	} else if t.size > 0 {
+		c.root = c.cloneNode(t.root)
+	}
+	return c
+}
+
+// copying the subtree `n` into the tree.
+func (t *TrieOf[V]) cloneNode(n node[V]) node[V] {
+	if in := n.internal; in != nil {
+		c := t.copyInternal(in)
+		c.gen = t.gen
+		for i := 0; i < 2; i++ {
+			c.child[i] = t.cloneNode(in.child[i])
+		}
+		return node[V]{internal: c}
+	}
+	return node[V]{external: t.newExternal(n.external.key, n.external.value)}
+}
+
+// returning a read-only point-in-time view of a tree in O(1).
+// The view shares nodes with the original, which copies a shared node before changing it,
+// so the view is not affected by later changes to the original and can be read concurrently with them.
+// Like any PersistentTrie, changing the view returns a new version without changing the original.
+// Snapshot itself must not be called concurrently with changes to the original.
+//
+// Since the original does not know when views are dropped, it keeps paying for copy-on-write
+// after the first Snapshot until Clear: Set of an existing key allocates a new external node,
+// Delete descends twice, and the first change below a shared node copies the path to it.
+func (t *TrieOf[V]) Snapshot() *PersistentTrieOf[V] {
+	return &PersistentTrieOf[V]{*t.snapshot()}
+}
+
+// returning a tree sharing nodes with `t` in O(1), both copying shared nodes before changing them.
+func (t *TrieOf[V]) snapshot() *TrieOf[V] {
+	s := &TrieOf[V]{root: t.root, size: t.size, opts: t.opts, gen: generation.Add(1)}
+	t.gen = generation.Add(1)
+	if t.arena != nil {
+		s.arena = t.arena.snapshot()
+	}
+	return s
+}
+
 // clearing a tree.
-func (t *Trie) Clear() {
+// Nodes are no longer shared with snapshots, so that changes stop paying for copy-on-write.
+func (t *TrieOf[V]) Clear() {
 	t.root.internal = nil
 	t.root.external = nil
 	t.size = 0
+	t.gen = 0
+	t.arena = nil
 }
 
 // return the number of key in a tree.
-func (t *Trie) Size() int {
+func (t *TrieOf[V]) Size() int {
 	return t.size
 }
 
 // fetching elements with a given prefix.
 // handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
-func (t *Trie) Allprefixed(prefix []byte, handle func(key []byte, value interface{}) bool) bool {
+func (t *TrieOf[V]) Allprefixed(prefix []byte, handle func(key []byte, value V) bool) bool {
+	if a := t.arena; a != nil {
+		if top, ok := a.prefixed(prefix); ok {
+			return a.allprefixed(top, handle)
+		}
+		return true
+	}
+	if top := t.prefixed(prefix); top != nil {
+		return allprefixed(top, handle)
+	}
+	return true
+}
+
+// fetching elements with a given prefix in reverse order.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *TrieOf[V]) AllprefixedReverse(prefix []byte, handle func(key []byte, value V) bool) bool {
+	if a := t.arena; a != nil {
+		if top, ok := a.prefixed(prefix); ok {
+			return a.allprefixedReverse(top, handle)
+		}
+		return true
+	}
+	if top := t.prefixed(prefix); top != nil {
+		return allprefixedReverse(top, handle)
+	}
+	return true
+}
+
+// returns the top node of elements with a given prefix, or nil if not found.
+func (t *TrieOf[V]) prefixed(prefix []byte) *node[V] {
 	// an empty tree
 	if t.size == 0 {
-		return true
+		return nil
 	}
+	return prefixedNode(&t.root, prefix)
+}
 
+// returns the top node of elements with a given prefix in the subtree `p`, or nil if not found.
+func prefixedNode[V any](p *node[V], prefix []byte) *node[V] {
 	// walk tree, maintaining top pointer
-	p := &t.root
 	top := p
 	if len(prefix) > 0 {
 		for q := p.internal; q != nil; q = p.internal {
@@ -245,14 +484,13 @@ func (t *Trie) Allprefixed(prefix []byte, handle func(key []byte, value interfac
 
 		// check prefix
 		if !bytes.HasPrefix(p.external.key, prefix) {
-			return true
+			return nil
 		}
 	}
-
-	return allprefixed(top, handle)
+	return top
 }
 
-func allprefixed(n *node, handle func([]byte, interface{}) bool) bool {
+func allprefixed[V any](n *node[V], handle func([]byte, V) bool) bool {
 	if n.internal != nil {
 		// dealing with an internal node while recursing
 		for i := 0; i < 2; i++ {
@@ -267,9 +505,25 @@ func allprefixed(n *node, handle func([]byte, interface{}) bool) bool {
 	return true
 }
 
+func allprefixedReverse[V any](n *node[V], handle func([]byte, V) bool) bool {
+	if n.internal != nil {
+		for i := 1; i >= 0; i-- {
+			if !allprefixedReverse(&n.internal.child[i], handle) {
+				return false
+			}
+		}
+	} else {
+		return handle(n.external.key, n.external.value)
+	}
+	return true
+}
+
 // Search for the longest matching key from the beginning of the given key.
 // if `key` is in Trie, `ok` is true.
-func (t *Trie) LongestPrefix(given []byte) (key []byte, value interface{}, ok bool) {
+func (t *TrieOf[V]) LongestPrefix(given []byte) (key []byte, value V, ok bool) {
+	if a := t.arena; a != nil {
+		return a.longestPrefix(a.root, given)
+	}
 	// an empty tree
 	if t.size == 0 {
 		return
@@ -277,7 +531,7 @@ func (t *Trie) LongestPrefix(given []byte) (key []byte, value interface{}, ok bo
 	return longestPrefix(&t.root, given)
 }
 
-func longestPrefix(n *node, key []byte) ([]byte, interface{}, bool) {
+func longestPrefix[V any](n *node[V], key []byte) (k []byte, v V, ok bool) {
 	if n.internal != nil {
 		direction := n.internal.direction(key)
 		if k, v, ok := longestPrefix(&n.internal.child[direction], key); ok {
@@ -291,12 +545,22 @@ func longestPrefix(n *node, key []byte) ([]byte, interface{}, bool) {
 			return n.external.key, n.external.value, true
 		}
 	}
-	return nil, nil, false
+	return
 }
 
 // Iterating elements from a given start key.
 // handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
-func (t *Trie) Walk(start []byte, handle func(key []byte, value interface{}) bool) bool {
+func (t *TrieOf[V]) Walk(start []byte, handle func(key []byte, value V) bool) bool {
+	if a := t.arena; a != nil {
+		if start == nil {
+			return a.allprefixed(a.root, handle)
+		}
+		// key is not in Trie
+		if !t.Contains(start) {
+			return false
+		}
+		return a.walkFrom(a.root, start, nil, true, handle)
+	}
 	if t.size == 0 {
 		return true
 	}
@@ -307,7 +571,7 @@ func (t *Trie) Walk(start []byte, handle func(key []byte, value interface{}) boo
 	return walk(&t.root, start, &seek, handle)
 }
 
-func walk(n *node, key []byte, seek *bool, handle func([]byte, interface{}) bool) bool {
+func walk[V any](n *node[V], key []byte, seek *bool, handle func([]byte, V) bool) bool {
 	if n.internal != nil {
 		var direction int
 		if *seek {
@@ -335,18 +599,337 @@ func walk(n *node, key []byte, seek *bool, handle func([]byte, interface{}) bool
 	}
 }
 
+// Iterating elements from the first key that is equal to or greater than a given start key.
+// Unlike Walk, `start` does not need to be in Trie.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *TrieOf[V]) WalkFrom(start []byte, handle func(key []byte, value V) bool) bool {
+	return t.walkFrom(start, true, handle)
+}
+
+// Iterating elements from the first key that is greater than a given start key.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *TrieOf[V]) WalkAfter(start []byte, handle func(key []byte, value V) bool) bool {
+	return t.walkFrom(start, false, handle)
+}
+
+func (t *TrieOf[V]) walkFrom(start []byte, inclusive bool, handle func([]byte, V) bool) bool {
+	if a := t.arena; a != nil {
+		return a.walkFrom(a.root, start, a.critNode(start), inclusive, handle)
+	}
+	if t.size == 0 {
+		return true
+	}
+	return walkFrom(&t.root, start, t.critNode(start), inclusive, handle)
+}
+
+// returns the critical bit of the node that would be created by inserting `key`, or nil if `key` is in Trie.
+func (t *TrieOf[V]) critNode(key []byte) *critBit {
+	offset, bit, cont := t.search(key).external.criticalBit(key)
+	if offset == -1 {
+		return nil
+	}
+	return &critBit{offset: offset, bit: bit, cont: cont}
+}
+
+// whether `n` is above the position where `crit` would be inserted.
+// At the same offset, a node testing the existence of the byte is above nodes testing its bits,
+// since shorter keys are smaller.
+func (n *critBit) above(crit *critBit) bool {
+	return crit == nil || n.offset < crit.offset ||
+		(n.offset == crit.offset && (n.cont || (!crit.cont && n.bit >= crit.bit)))
+}
+
+func walkFrom[V any](n *node[V], key []byte, crit *critBit, inclusive bool, handle func([]byte, V) bool) bool {
+	if in := n.internal; in != nil {
+		if in.above(crit) {
+			if in.direction(key) == 1 {
+				return walkFrom(&in.child[1], key, crit, inclusive, handle)
+			}
+			if !walkFrom(&in.child[0], key, crit, inclusive, handle) {
+				return false
+			}
+			return allprefixed(&in.child[1], handle)
+		}
+	} else if crit == nil {
+		// reached the key itself
+		if inclusive {
+			return handle(n.external.key, n.external.value)
+		}
+		return true
+	}
+
+	// all keys of the subtree differ from the key at the critical bit
+	if crit.direction(key) == 0 {
+		return allprefixed(n, handle)
+	}
+	return true
+}
+
+// Iterating elements in reverse order from the last key that is equal to or less than a given start key.
+// If `start` is nil, the iteration starts from the last key in Trie.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *TrieOf[V]) WalkReverse(start []byte, handle func(key []byte, value V) bool) bool {
+	if start == nil {
+		if a := t.arena; a != nil {
+			return a.allprefixedReverse(a.root, handle)
+		}
+		if t.size == 0 {
+			return true
+		}
+		return allprefixedReverse(&t.root, handle)
+	}
+	return t.walkReverse(start, true, handle)
+}
+
+func (t *TrieOf[V]) walkReverse(start []byte, inclusive bool, handle func([]byte, V) bool) bool {
+	if a := t.arena; a != nil {
+		return a.walkFromReverse(a.root, start, a.critNode(start), inclusive, handle)
+	}
+	if t.size == 0 {
+		return true
+	}
+	return walkFromReverse(&t.root, start, t.critNode(start), inclusive, handle)
+}
+
+func walkFromReverse[V any](n *node[V], key []byte, crit *critBit, inclusive bool, handle func([]byte, V) bool) bool {
+	if in := n.internal; in != nil {
+		if in.above(crit) {
+			if in.direction(key) == 0 {
+				return walkFromReverse(&in.child[0], key, crit, inclusive, handle)
+			}
+			if !walkFromReverse(&in.child[1], key, crit, inclusive, handle) {
+				return false
+			}
+			return allprefixedReverse(&in.child[0], handle)
+		}
+	} else if crit == nil {
+		// reached the key itself
+		if inclusive {
+			return handle(n.external.key, n.external.value)
+		}
+		return true
+	}
+
+	// all keys of the subtree differ from the key at the critical bit
+	if crit.direction(key) == 1 {
+		return allprefixedReverse(n, handle)
+	}
+	return true
+}
+
+// return the smallest key in a tree.
+// if Trie is empty, `ok` is false.
+func (t *TrieOf[V]) Min() (key []byte, value V, ok bool) {
+	return t.edge(0)
+}
+
+// return the largest key in a tree.
+// if Trie is empty, `ok` is false.
+func (t *TrieOf[V]) Max() (key []byte, value V, ok bool) {
+	return t.edge(1)
+}
+
+func (t *TrieOf[V]) edge(direction int) (key []byte, value V, ok bool) {
+	if a := t.arena; a != nil {
+		ref := a.edge(direction)
+		return a.key(ref), a.value(ref), true
+	}
+	if t.size == 0 {
+		return
+	}
+	n := &t.root
+	for n.internal != nil {
+		n = &n.internal.child[direction]
+	}
+	return n.external.key, n.external.value, true
+}
+
+// return the largest key equal to or less than a given key.
+// if such a key is not in Trie, `ok` is false.
+func (t *TrieOf[V]) Floor(given []byte) (key []byte, value V, ok bool) {
+	t.walkReverse(given, true, func(k []byte, v V) bool {
+		key, value, ok = k, v, true
+		return false
+	})
+	return
+}
+
+// return the smallest key equal to or greater than a given key.
+// if such a key is not in Trie, `ok` is false.
+func (t *TrieOf[V]) Ceiling(given []byte) (key []byte, value V, ok bool) {
+	t.walkFrom(given, true, func(k []byte, v V) bool {
+		key, value, ok = k, v, true
+		return false
+	})
+	return
+}
+
+// return the largest key strictly less than a given key.
+// if such a key is not in Trie, `ok` is false.
+func (t *TrieOf[V]) Lower(given []byte) (key []byte, value V, ok bool) {
+	t.walkReverse(given, false, func(k []byte, v V) bool {
+		key, value, ok = k, v, true
+		return false
+	})
+	return
+}
+
+// return the smallest key strictly greater than a given key.
+// if such a key is not in Trie, `ok` is false.
+func (t *TrieOf[V]) Higher(given []byte) (key []byte, value V, ok bool) {
+	t.walkFrom(given, false, func(k []byte, v V) bool {
+		key, value, ok = k, v, true
+		return false
+	})
+	return
+}
+
+// Options for the ends of Range.
+type RangeMode uint8
+
+const (
+	// Excluding the lower bound from the range.
+	ExcludeLower RangeMode = 1 << iota
+	// Including the upper bound in the range.
+	IncludeUpper
+	// Removing the upper bound from the range, `hi` is ignored.
+	// This is the way to leave the range of SortedMap unbounded, since its keys are not nil.
+	UnboundedUpper
+)
+
+// Iterating elements between `lo` and `hi`.
+// By default the range is half-open `[lo, hi)`, the ends can be changed by `mode`.
+// If `hi` is nil, the range has no upper bound.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *TrieOf[V]) Range(lo, hi []byte, mode RangeMode, handle func(key []byte, value V) bool) bool {
+	if mode&UnboundedUpper != 0 {
+		hi = nil
+	}
+	limit := 0
+	if mode&IncludeUpper != 0 {
+		limit = 1
+	}
+	var reached bool
+	wrapper := func(key []byte, value V) bool {
+		if hi != nil && bytes.Compare(key, hi) >= limit {
+			reached = true
+			return false
+		}
+		return handle(key, value)
+	}
+	return t.walkFrom(lo, mode&ExcludeLower == 0, wrapper) || reached
+}
+
+// return the number of keys less than a given key.
+// without WithOrderStatistics, it takes O(n) time.
+func (t *TrieOf[V]) Rank(key []byte) int {
+	return t.rank(key, false)
+}
+
+// return the element at a given index in sorted order (starting from 0).
+// if `i` is out of range, `ok` is false.
+// without WithOrderStatistics, it takes O(n) time.
+func (t *TrieOf[V]) Select(i int) (key []byte, value V, ok bool) {
+	if i < 0 || i >= t.size {
+		return
+	}
+	if a := t.arena; a != nil {
+		ref := a.nth(i)
+		return a.key(ref), a.value(ref), true
+	}
+	n := &t.root
+	for in := n.internal; in != nil; in = n.internal {
+		if c := t.count(&in.child[0]); i >= c {
+			i -= c
+			n = &in.child[1]
+		} else {
+			n = &in.child[0]
+		}
+	}
+	return n.external.key, n.external.value, true
+}
+
+// return the number of keys between `lo` and `hi`.
+// The ends of the range are handled in the same way as Range.
+// without WithOrderStatistics, it takes O(n) time.
+func (t *TrieOf[V]) CountRange(lo, hi []byte, mode RangeMode) int {
+	c := t.size
+	if hi != nil && mode&UnboundedUpper == 0 {
+		c = t.rank(hi, mode&IncludeUpper != 0)
+	}
+	if c -= t.rank(lo, mode&ExcludeLower != 0); c < 0 {
+		return 0
+	}
+	return c
+}
+
+// return the number of keys less than (or equal to, if `inclusive`) a given key.
+func (t *TrieOf[V]) rank(key []byte, inclusive bool) (r int) {
+	if a := t.arena; a != nil {
+		return a.rank(key, inclusive)
+	}
+	if t.size == 0 {
+		return
+	}
+	crit := t.critNode(key)
+	n := &t.root
+	for in := n.internal; in != nil && in.above(crit); in = n.internal {
+		if in.direction(key) == 1 {
+			r += t.count(&in.child[0])
+			n = &in.child[1]
+		} else {
+			n = &in.child[0]
+		}
+	}
+
+	if crit == nil {
+		// reached the key itself
+		if inclusive {
+			r += 1
+		}
+	} else if crit.direction(key) == 1 {
+		// all keys of the subtree are less than the key
+		r += t.count(n)
+	}
+	return
+}
+
+// return the number of keys in the subtree.
+func (t *TrieOf[V]) count(n *node[V]) (c int) {
+	if t.opts.counted || n.internal == nil {
+		return n.count()
+	}
+	allprefixed(n, func([]byte, V) bool {
+		c += 1
+		return true
+	})
+	return
+}
+
+// return the maintained number of keys in the subtree (with WithOrderStatistics).
+func (n *node[V]) count() int {
+	if n.internal != nil {
+		return n.internal.counted().count
+	}
+	return 1
+}
+
 // dump tree. (for debugging)
-func (t *Trie) Dump(w io.Writer) {
-	if t.root.internal == nil && t.root.external == nil {
+func (t *TrieOf[V]) Dump(w io.Writer) {
+	root := t.root
+	if a := t.arena; a != nil {
+		root = a.expand(a.root)
+	}
+	if root.internal == nil && root.external == nil {
 		return
 	}
 	if w == nil {
 		w = os.Stdout
 	}
-	dump(w, &t.root, true, "")
+	dump(w, &root, true, "")
 }
 
-func dump(w io.Writer, n *node, right bool, prefix string) {
+func dump[V any](w io.Writer, n *node[V], right bool, prefix string) {
 	var ownprefix string
 	if right {
 		ownprefix = prefix
@@ -383,9 +966,71 @@ func key2str(key []byte) string {
 	return string(key)
 }
 
+// Option configures a tree at construction.
+type Option func(*options)
+
+type options struct {
+	counted  bool
+	copyKeys bool
+	arena    bool
+	codec    interface{}    // ValueCodec of values (with WithValueCodec)
+	mapped   MappedPolicy   // IPv4-mapped IPv6 addresses of Net (with WithMappedAddrs)
+	hostBits HostBitsPolicy // host bits of routes of Net (with WithHostBits)
+}
+
+// returning the key to store, copying it with WithCopyKeys.
+func (o *options) storedKey(key []byte) []byte {
+	if o.copyKeys {
+		return bytes.Clone(key)
+	}
+	return key
+}
+
+// WithOrderStatistics maintains the number of keys in each subtree,
+// so that Rank, Select and CountRange run in O(depth).
+// It costs an extra field per internal node and a second descent per Delete,
+// which trees without the option do not pay.
+func WithOrderStatistics() Option {
+	return func(o *options) {
+		o.counted = true
+	}
+}
+
+// WithCopyKeys copies keys given to Insert and Set before storing them,
+// so that the caller can reuse the buffer of a key.
+func WithCopyKeys() Option {
+	return func(o *options) {
+		o.copyKeys = true
+	}
+}
+
+// WithArena stores nodes in slices where they refer to each other by 32-bit handles, and packs
+// keys into a single byte slice, so that a tree consists of a few large objects without pointers
+// (unless V contains pointers) which the garbage collector does not scan. Keys are copied into
+// the arena, and a tree holds at most 2^31 keys.
+// Slots of deleted nodes are reused, and keys are reclaimed by copying the tree into a new arena
+// once more than half of it is unused, which takes O(n) time amortized over the changes since the last copy.
+// Snapshot shares the arena in O(1), and nodes shared with it are copied before being changed.
+// Set operations and Diff walk all keys of an operand in an arena instead of sharing subtrees.
+// PersistentTrie and ConcurrentTrie only copy keys.
+func WithArena() Option {
+	return func(o *options) {
+		o.arena = true
+	}
+}
+
 // create a tree.
-func NewTrie() *Trie {
-	return &Trie{}
+func NewTrie(opts ...Option) *Trie {
+	return NewTrieOf[interface{}](opts...)
+}
+
+// create a tree holding values of type V.
+func NewTrieOf[V any](opts ...Option) *TrieOf[V] {
+	t := &TrieOf[V]{}
+	for _, opt := range opts {
+		opt(&t.opts)
+	}
+	return t
 }
 
 func init() {
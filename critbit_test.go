@@ -2,7 +2,11 @@ package critbitgo_test
 
 import (
 	"bytes"
+	"errors"
 	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
 	"testing"
 
 	"github.com/k-sone/critbitgo"
@@ -24,6 +28,32 @@ func dumpTrie(trie *critbitgo.Trie) string {
 	return buf.String()
 }
 
+// returns a generator of random keys shorter than `n` bytes.
+// Keys are made of a few bytes so that they often share prefixes and contain zero bytes.
+func randomKey(random *rand.Rand, n int) func() []byte {
+	return func() []byte {
+		key := make([]byte, random.Intn(n))
+		for i := range key {
+			key[i] = []byte{0x00, 0x01, 0x40, 0x61, 0xff}[random.Intn(5)]
+		}
+		return key
+	}
+}
+
+// prefixed is the read surface toMap needs, shared by Trie and PersistentTrie.
+type prefixed[V any] interface {
+	Allprefixed(prefix []byte, handle func(key []byte, value V) bool) bool
+}
+
+func toMap[V any](trie prefixed[V]) map[string]V {
+	m := make(map[string]V)
+	trie.Allprefixed(nil, func(k []byte, v V) bool {
+		m[string(k)] = v
+		return true
+	})
+	return m
+}
+
 func TestInsert(t *testing.T) {
 	// normal build
 	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
@@ -330,6 +360,708 @@ func TestWalk(t *testing.T) {
 	}
 }
 
+func TestWalkFrom(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	trie := buildTrie(t, keys)
+
+	var elems []string
+	handle := func(key []byte, value interface{}) bool {
+		if k := string(key); k == value {
+			elems = append(elems, k)
+		}
+		return true
+	}
+
+	sorted := []string{"", "a", "aa", "ab", "aba", "b", "ba", "bab", "bb"}
+	expects := map[string]int{
+		"":    0,
+		"a":   1,
+		"a^":  2,
+		"aaa": 3,
+		"ab":  3,
+		"abb": 5,
+		"b":   5,
+		"ba":  6,
+		"bc":  9,
+		"c":   9,
+	}
+	for start, i := range expects {
+		elems = []string{}
+		if !trie.WalkFrom([]byte(start), handle) {
+			t.Error("WalkFrom() - invalid result")
+		}
+		if !reflect.DeepEqual(elems, sorted[i:]) {
+			t.Errorf("WalkFrom() - %s: invalid elems [%v]", start, elems)
+		}
+
+		if i < len(sorted) && sorted[i] == start {
+			i++
+		}
+		elems = []string{}
+		if !trie.WalkAfter([]byte(start), handle) {
+			t.Error("WalkAfter() - invalid result")
+		}
+		if !reflect.DeepEqual(elems, sorted[i:]) {
+			t.Errorf("WalkAfter() - %s: invalid elems [%v]", start, elems)
+		}
+	}
+
+	elems = []string{}
+	if !trie.WalkFrom(nil, handle) || !reflect.DeepEqual(elems, sorted) {
+		t.Errorf("WalkFrom() - invalid elems [%v]", elems)
+	}
+
+	elems = []string{}
+	handle = func(key []byte, value interface{}) bool {
+		elems = append(elems, string(key))
+		return string(key) != "b"
+	}
+	if trie.WalkFrom([]byte("ac"), handle) || !reflect.DeepEqual(elems, []string{"b"}) {
+		t.Errorf("WalkFrom() - invalid elems on abort [%v]", elems)
+	}
+}
+
+func TestWalkFromRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	genKey := randomKey(random, 4)
+
+	for i := 0; i < 50; i++ {
+		trie := critbitgo.NewTrie()
+		var sorted [][]byte
+		for j := 0; j < 30; j++ {
+			if key := genKey(); trie.Insert(key, nil) {
+				sorted = append(sorted, key)
+			}
+		}
+		sort.Slice(sorted, func(a, b int) bool { return bytes.Compare(sorted[a], sorted[b]) < 0 })
+
+		for j := 0; j < 30; j++ {
+			start := genKey()
+			var exp, ret [][]byte
+			for _, key := range sorted {
+				if bytes.Compare(key, start) >= 0 {
+					exp = append(exp, key)
+				}
+			}
+			trie.WalkFrom(start, func(key []byte, _ interface{}) bool {
+				ret = append(ret, key)
+				return true
+			})
+			if !reflect.DeepEqual(exp, ret) {
+				t.Errorf("WalkFrom() - %x: expected [%x], actual [%x]\n%s", start, exp, ret, dumpTrie(trie))
+			}
+		}
+	}
+}
+
+func TestReverse(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	trie := buildTrie(t, keys)
+
+	var elems []string
+	handle := func(key []byte, value interface{}) bool {
+		if k := string(key); k == value {
+			elems = append(elems, k)
+		}
+		return true
+	}
+
+	elems = []string{}
+	if !trie.AllprefixedReverse([]byte("a"), handle) {
+		t.Error("AllprefixedReverse() - invalid result")
+	}
+	if exp := []string{"aba", "ab", "aa", "a"}; !reflect.DeepEqual(elems, exp) {
+		t.Errorf("AllprefixedReverse() - invalid elems [%v]", elems)
+	}
+
+	elems = []string{}
+	if !trie.AllprefixedReverse([]byte("c"), handle) || len(elems) != 0 {
+		t.Errorf("AllprefixedReverse() - invalid elems [%v]", elems)
+	}
+
+	elems = []string{}
+	if !trie.WalkReverse(nil, handle) {
+		t.Error("WalkReverse() - invalid result")
+	}
+	if exp := []string{"bb", "bab", "ba", "b", "aba", "ab", "aa", "a", ""}; !reflect.DeepEqual(elems, exp) {
+		t.Errorf("WalkReverse() - invalid elems [%v]", elems)
+	}
+
+	elems = []string{}
+	if !trie.WalkReverse([]byte("abb"), handle) {
+		t.Error("WalkReverse() - invalid result")
+	}
+	if exp := []string{"aba", "ab", "aa", "a", ""}; !reflect.DeepEqual(elems, exp) {
+		t.Errorf("WalkReverse() - invalid elems [%v]", elems)
+	}
+
+	elems = []string{}
+	handle = func(key []byte, value interface{}) bool {
+		elems = append(elems, string(key))
+		return string(key) != "ab"
+	}
+	if trie.WalkReverse([]byte("ab"), handle) || !reflect.DeepEqual(elems, []string{"ab"}) {
+		t.Errorf("WalkReverse() - invalid elems on abort [%v]", elems)
+	}
+}
+
+func TestWalkReverseRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	genKey := randomKey(random, 4)
+
+	for i := 0; i < 50; i++ {
+		trie := critbitgo.NewTrie()
+		var sorted [][]byte
+		for j := 0; j < 30; j++ {
+			if key := genKey(); trie.Insert(key, nil) {
+				sorted = append(sorted, key)
+			}
+		}
+		sort.Slice(sorted, func(a, b int) bool { return bytes.Compare(sorted[a], sorted[b]) > 0 })
+
+		for j := 0; j < 30; j++ {
+			start := genKey()
+			var exp, ret [][]byte
+			for _, key := range sorted {
+				if bytes.Compare(key, start) <= 0 {
+					exp = append(exp, key)
+				}
+			}
+			trie.WalkReverse(start, func(key []byte, _ interface{}) bool {
+				ret = append(ret, key)
+				return true
+			})
+			if !reflect.DeepEqual(exp, ret) {
+				t.Errorf("WalkReverse() - %x: expected [%x], actual [%x]\n%s", start, exp, ret, dumpTrie(trie))
+			}
+		}
+	}
+}
+
+func TestNavigation(t *testing.T) {
+	keys := []string{"a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	trie := buildTrie(t, keys)
+
+	if key, value, ok := trie.Min(); !ok || string(key) != "a" || value != "a" {
+		t.Errorf("Min() - invalid result - %s", key)
+	}
+	if key, value, ok := trie.Max(); !ok || string(key) != "bb" || value != "bb" {
+		t.Errorf("Max() - invalid result - %s", key)
+	}
+
+	type navigation func([]byte) ([]byte, interface{}, bool)
+	check := func(name string, f navigation, given, expect string) {
+		key, value, ok := f([]byte(given))
+		if expect == "-" {
+			if ok {
+				t.Errorf("%s() - %s: phantom found %s", name, given, key)
+			}
+		} else if !ok || string(key) != expect || value != expect {
+			t.Errorf("%s() - %s: expected [%s], actual [%s]", name, given, expect, key)
+		}
+	}
+
+	// given, Floor, Ceiling, Lower, Higher
+	expects := [][5]string{
+		{"", "-", "a", "-", "a"},
+		{"a", "a", "a", "-", "aa"},
+		{"a^", "a", "aa", "a", "aa"},
+		{"ab", "ab", "ab", "aa", "aba"},
+		{"abc", "aba", "b", "aba", "b"},
+		{"bb", "bb", "bb", "bab", "-"},
+		{"c", "bb", "-", "bb", "-"},
+	}
+	for _, e := range expects {
+		check("Floor", trie.Floor, e[0], e[1])
+		check("Ceiling", trie.Ceiling, e[0], e[2])
+		check("Lower", trie.Lower, e[0], e[3])
+		check("Higher", trie.Higher, e[0], e[4])
+	}
+
+	trie.Clear()
+	if _, _, ok := trie.Min(); ok {
+		t.Error("Min() - phantom found")
+	}
+	if _, _, ok := trie.Max(); ok {
+		t.Error("Max() - phantom found")
+	}
+	check("Floor", trie.Floor, "a", "-")
+	check("Ceiling", trie.Ceiling, "a", "-")
+}
+
+func TestOrderStatistics(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	genKey := randomKey(random, 4)
+
+	for _, trie := range []*critbitgo.Trie{
+		critbitgo.NewTrie(),
+		critbitgo.NewTrie(critbitgo.WithOrderStatistics()),
+		critbitgo.NewTrie(critbitgo.WithArena()),
+		critbitgo.NewTrie(critbitgo.WithArena(), critbitgo.WithOrderStatistics()),
+	} {
+		for i := 0; i < 200; i++ {
+			if random.Intn(3) == 0 {
+				trie.Delete(genKey())
+			} else {
+				trie.Set(genKey(), nil)
+			}
+
+			var sorted [][]byte
+			trie.Allprefixed(nil, func(key []byte, _ interface{}) bool {
+				sorted = append(sorted, key)
+				return true
+			})
+			for j, key := range sorted {
+				if r := trie.Rank(key); r != j {
+					t.Fatalf("Rank() - %x: expected [%d], actual [%d]\n%s", key, j, r, dumpTrie(trie))
+				}
+				if k, _, ok := trie.Select(j); !ok || !bytes.Equal(k, key) {
+					t.Fatalf("Select() - %d: expected [%x], actual [%x]\n%s", j, key, k, dumpTrie(trie))
+				}
+			}
+			if _, _, ok := trie.Select(len(sorted)); ok {
+				t.Fatal("Select() - phantom found")
+			}
+
+			lo, hi := genKey(), genKey()
+			mode := critbitgo.RangeMode(random.Intn(8))
+			var c int
+			trie.Range(lo, hi, mode, func([]byte, interface{}) bool {
+				c += 1
+				return true
+			})
+			if n := trie.CountRange(lo, hi, mode); n != c {
+				t.Fatalf("CountRange() - %x, %x, %d: expected [%d], actual [%d]", lo, hi, mode, c, n)
+			}
+			if n := trie.CountRange(lo, nil, 0); n != len(sorted)-trie.Rank(lo) {
+				t.Fatalf("CountRange() - %x: unbounded [%d]", lo, n)
+			}
+			if n := trie.CountRange(lo, hi, critbitgo.UnboundedUpper); n != len(sorted)-trie.Rank(lo) {
+				t.Fatalf("CountRange() - %x, %x: unbounded [%d]", lo, hi, n)
+			}
+		}
+	}
+}
+
+func TestRange(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	trie := buildTrie(t, keys)
+
+	var elems []string
+	handle := func(key []byte, value interface{}) bool {
+		if k := string(key); k == value {
+			elems = append(elems, k)
+		}
+		return true
+	}
+
+	tests := []struct {
+		lo, hi []byte
+		mode   critbitgo.RangeMode
+		exp    []string
+	}{
+		{[]byte("a"), []byte("b"), 0, []string{"a", "aa", "ab", "aba"}},
+		{[]byte("a"), []byte("b"), critbitgo.ExcludeLower, []string{"aa", "ab", "aba"}},
+		{[]byte("a"), []byte("b"), critbitgo.IncludeUpper, []string{"a", "aa", "ab", "aba", "b"}},
+		{[]byte("a"), []byte("b"), critbitgo.ExcludeLower | critbitgo.IncludeUpper, []string{"aa", "ab", "aba", "b"}},
+		{[]byte("a^"), []byte("abc"), 0, []string{"aa", "ab", "aba"}},
+		{[]byte("ba"), nil, 0, []string{"ba", "bab", "bb"}},
+		{nil, []byte("aa"), 0, []string{"", "a"}},
+		{[]byte(""), []byte(""), critbitgo.IncludeUpper, []string{""}},
+		{[]byte("b"), []byte("a"), 0, []string{}},
+		{[]byte("c"), nil, 0, []string{}},
+	}
+	for _, test := range tests {
+		elems = []string{}
+		if !trie.Range(test.lo, test.hi, test.mode, handle) {
+			t.Error("Range() - invalid result")
+		}
+		if !reflect.DeepEqual(elems, test.exp) {
+			t.Errorf("Range() - %q, %q, %d: invalid elems [%v]", test.lo, test.hi, test.mode, elems)
+		}
+	}
+
+	elems = []string{}
+	handle = func(key []byte, value interface{}) bool {
+		elems = append(elems, string(key))
+		return string(key) != "aa"
+	}
+	if trie.Range([]byte("a"), []byte("b"), 0, handle) || !reflect.DeepEqual(elems, []string{"a", "aa"}) {
+		t.Errorf("Range() - invalid elems on abort [%v]", elems)
+	}
+}
+
+func TestClone(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	trie := buildTrie(t, keys)
+	clone := trie.Clone()
+	if d1, d2 := dumpTrie(trie), dumpTrie(clone); d1 != d2 {
+		t.Errorf("Clone() - different tries\norigin:\n%s\nclone:\n%s\n", d1, d2)
+	}
+
+	trie.Set([]byte("a"), 100)
+	trie.Delete([]byte("bb"))
+	clone.Insert([]byte("c"), "c")
+	if v, _ := clone.Get([]byte("a")); v != "a" {
+		t.Errorf("Clone() - changed by original - %v", v)
+	}
+	if !clone.Contains([]byte("bb")) || trie.Contains([]byte("c")) {
+		t.Error("Clone() - not independent")
+	}
+	if s := clone.Size(); s != len(keys)+1 {
+		t.Errorf("Clone() - invalid size [%d]", s)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	genKey := randomKey(random, 4)
+
+	for _, opts := range [][]critbitgo.Option{nil, {critbitgo.WithOrderStatistics()}, {critbitgo.WithArena(), critbitgo.WithOrderStatistics()}} {
+		trie := critbitgo.NewTrie(opts...)
+		model := make(map[string]interface{})
+		var snapshots []*critbitgo.PersistentTrie
+		var models []map[string]interface{}
+		for i := 0; i < 500; i++ {
+			// take a snapshot sometimes
+			if random.Intn(20) == 0 {
+				snapshots = append(snapshots, trie.Snapshot())
+				models = append(models, toMap(trie))
+			}
+
+			if key := genKey(); random.Intn(3) == 0 {
+				trie.Delete(key)
+				delete(model, string(key))
+			} else {
+				trie.Set(key, i)
+				model[string(key)] = i
+			}
+			if m := toMap(trie); !reflect.DeepEqual(m, model) {
+				t.Fatalf("invalid elems\n%v\n%v", m, model)
+			}
+
+			// snapshots are not changed by the original nor by their new versions
+			for j, snapshot := range snapshots {
+				if random.Intn(10) == 0 {
+					snapshot.Set(genKey(), -1)
+				}
+				if !reflect.DeepEqual(models[j], toMap(snapshot)) {
+					t.Fatalf("Snapshot() - version %d changed", j)
+				}
+				if snapshot.Size() != len(models[j]) {
+					t.Fatalf("Snapshot() - version %d: invalid size", j)
+				}
+				var index int
+				snapshot.Allprefixed(nil, func(k []byte, _ interface{}) bool {
+					if r := snapshot.Rank(k); r != index {
+						t.Fatalf("Snapshot() - version %d: invalid rank %x [%d]", j, k, r)
+					}
+					index++
+					return true
+				})
+			}
+		}
+		if err := trie.Validate(); err != nil {
+			t.Fatalf("Validate() - %s", err)
+		}
+	}
+}
+
+func TestNewTrieFromSorted(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	sorted := append([]string{}, keys...)
+	sort.Strings(sorted)
+	bkeys := make([][]byte, len(sorted))
+	values := make([]interface{}, len(sorted))
+	for i, key := range sorted {
+		bkeys[i] = []byte(key)
+		values[i] = key
+	}
+
+	trie, err := critbitgo.NewTrieFromSorted(bkeys, values)
+	if err != nil {
+		t.Fatalf("NewTrieFromSorted() - error occurred %s", err)
+	}
+	if d1, d2 := dumpTrie(buildTrie(t, keys)), dumpTrie(trie); d1 != d2 {
+		t.Errorf("NewTrieFromSorted() - different tries\ninserted:\n%s\nbuilt:\n%s\n", d1, d2)
+	}
+	if trie.Size() != len(keys) {
+		t.Errorf("NewTrieFromSorted() - invalid size [%d]", trie.Size())
+	}
+
+	trie, err = critbitgo.NewTrieFromSorted(bkeys, nil, critbitgo.WithOrderStatistics())
+	if err != nil {
+		t.Fatalf("NewTrieFromSorted() - error occurred %s", err)
+	}
+	if err := trie.Validate(); err != nil {
+		t.Errorf("NewTrieFromSorted() - %s", err)
+	}
+	for i, key := range bkeys {
+		if r := trie.Rank(key); r != i {
+			t.Errorf("Rank() - %q: invalid rank [%d]", key, r)
+		}
+		if v, ok := trie.Get(key); !ok || v != nil {
+			t.Errorf("Get() - %q: invalid value [%v]", key, v)
+		}
+	}
+
+	tests := []struct {
+		keys   []string
+		values []interface{}
+		err    error
+	}{
+		{[]string{"a", "b", "a"}, nil, critbitgo.ErrUnsortedKeys},
+		{[]string{"ab", "a"}, nil, critbitgo.ErrUnsortedKeys},
+		{[]string{"a", "b", "b"}, nil, critbitgo.ErrDuplicateKey},
+		{[]string{"a", "b"}, []interface{}{1}, nil},
+	}
+	for _, test := range tests {
+		bkeys := make([][]byte, len(test.keys))
+		for i, key := range test.keys {
+			bkeys[i] = []byte(key)
+		}
+		trie, err := critbitgo.NewTrieFromSorted(bkeys, test.values)
+		if trie != nil || err == nil || (test.err != nil && err != test.err) {
+			t.Errorf("NewTrieFromSorted() - %q: invalid result [%v]", test.keys, err)
+		}
+	}
+}
+
+func TestNewTrieOfFromSortedSeq(t *testing.T) {
+	keys := []string{"", "a", "aa", "ab", "aba", "b", "ba", "bab", "bb"}
+	seq := func(yield func([]byte, int) bool) {
+		for i, key := range keys {
+			if !yield([]byte(key), i) {
+				return
+			}
+		}
+	}
+	trie, err := critbitgo.NewTrieOfFromSortedSeq(seq)
+	if err != nil {
+		t.Fatalf("NewTrieOfFromSortedSeq() - error occurred %s", err)
+	}
+	var index int
+	for key, value := range trie.All() {
+		if string(key) != keys[index] || value != index {
+			t.Errorf("NewTrieOfFromSortedSeq() - invalid element %q, %d", key, value)
+		}
+		index++
+	}
+	if index != len(keys) {
+		t.Errorf("NewTrieOfFromSortedSeq() - invalid size [%d]", index)
+	}
+}
+
+func TestSetOperations(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	genKey := randomKey(random, 5)
+	checkedMap := func(trie *critbitgo.TrieOf[int]) map[string]int {
+		if err := trie.Validate(); err != nil {
+			t.Fatal(err)
+		}
+		var n int
+		var prev []byte
+		trie.Allprefixed(nil, func(k []byte, _ int) bool {
+			if n > 0 && bytes.Compare(prev, k) >= 0 {
+				t.Fatalf("invalid order %x, %x", prev, k)
+			}
+			if r := trie.Rank(k); r != n {
+				t.Fatalf("invalid rank %x [%d]", k, r)
+			}
+			prev = k
+			n++
+			return true
+		})
+		m := toMap(trie)
+		if trie.Size() != len(m) {
+			t.Fatalf("invalid size [%d, %d]", trie.Size(), len(m))
+		}
+		for k, v := range m {
+			if got, ok := trie.Get([]byte(k)); !ok || got != v {
+				t.Fatalf("Get() - %x: invalid value [%d]", k, got)
+			}
+		}
+		return m
+	}
+	sum := func(_ []byte, a, b int) int {
+		return a + b
+	}
+	options := [][]critbitgo.Option{nil, {critbitgo.WithOrderStatistics()}, {critbitgo.WithArena()}}
+
+	for i := 0; i < 200; i++ {
+		a := critbitgo.NewTrieOf[int](options[random.Intn(len(options))]...)
+		b := critbitgo.NewTrieOf[int](options[random.Intn(len(options))]...)
+		for n := random.Intn(60); n > 0; n-- {
+			a.Set(genKey(), random.Intn(100))
+		}
+		for n := random.Intn(60); n > 0; n-- {
+			b.Set(genKey(), random.Intn(100))
+		}
+		ma, mb := checkedMap(a), checkedMap(b)
+		// nodes of snapshotted operands are shared with results, others are copied
+		if random.Intn(2) == 0 {
+			a.Snapshot()
+		}
+		if random.Intn(2) == 0 {
+			b.Snapshot()
+		}
+
+		union, intersect, difference, symmetric := map[string]int{}, map[string]int{}, map[string]int{}, map[string]int{}
+		for k, v := range ma {
+			if w, ok := mb[k]; ok {
+				union[k] = v + w
+				intersect[k] = v + w
+			} else {
+				union[k] = v
+				difference[k] = v
+				symmetric[k] = v
+			}
+		}
+		for k, w := range mb {
+			if _, ok := ma[k]; !ok {
+				union[k] = w
+				symmetric[k] = w
+			}
+		}
+
+		results := []*critbitgo.TrieOf[int]{
+			a.Union(b, sum),
+			a.Intersect(b, sum),
+			a.Difference(b),
+			a.SymmetricDifference(b),
+		}
+		for j, exp := range []map[string]int{union, intersect, difference, symmetric} {
+			if m := checkedMap(results[j]); !reflect.DeepEqual(m, exp) {
+				t.Fatalf("set operation %d - invalid result\n%v\n%v", j, m, exp)
+			}
+		}
+
+		// operands and results are independent
+		tries := append(results, a, b)
+		models := []map[string]int{union, intersect, difference, symmetric, ma, mb}
+		for j, trie := range tries {
+			for n := 0; n < 10; n++ {
+				if key := genKey(); random.Intn(2) == 0 {
+					trie.Delete(key)
+					delete(models[j], string(key))
+				} else {
+					trie.Set(key, -j)
+					models[j][string(key)] = -j
+				}
+			}
+		}
+		for j, trie := range tries {
+			if m := checkedMap(trie); !reflect.DeepEqual(m, models[j]) {
+				t.Fatalf("set operation - tree %d changed by others\n%v\n%v", j, m, models[j])
+			}
+		}
+	}
+
+	a := critbitgo.NewTrieOf[int]()
+	a.Set([]byte("a"), 1)
+	a.Set([]byte("b"), 2)
+	b := critbitgo.NewTrieOf[int]()
+	b.Set([]byte("b"), 20)
+	b.Set([]byte("c"), 30)
+	if v, _ := a.Union(b, nil).Get([]byte("b")); v != 2 {
+		t.Errorf("Union() - invalid value without merge [%d]", v)
+	}
+	if v, _ := a.Intersect(b, nil).Get([]byte("b")); v != 2 {
+		t.Errorf("Intersect() - invalid value without merge [%d]", v)
+	}
+}
+
+func TestCopyKeys(t *testing.T) {
+	buf := []byte("aa")
+	trie := critbitgo.NewTrie(critbitgo.WithCopyKeys())
+	trie.Insert(buf, 1)
+	buf[1] = 'b'
+	trie.Set(buf, 2)
+	buf[1] = 'c'
+	trie.Set(buf[:1], 3)
+	buf[0] = 'x'
+	var keys []string
+	for key := range trie.All() {
+		keys = append(keys, string(key))
+	}
+	if !reflect.DeepEqual(keys, []string{"a", "aa", "ab"}) {
+		t.Errorf("WithCopyKeys() - keys changed by the caller %q", keys)
+	}
+
+	p, _ := critbitgo.NewPersistentTrie(critbitgo.WithCopyKeys()).Insert(buf, 1)
+	buf[0] = 'y'
+	if !p.Contains([]byte("xc")) {
+		t.Error("WithCopyKeys() - persistent key changed by the caller")
+	}
+}
+
+func TestStats(t *testing.T) {
+	if s := critbitgo.NewTrie().Stats(); s != (critbitgo.Stats{}) {
+		t.Errorf("Stats() - empty tree: invalid stats %+v", s)
+	}
+
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	s := buildTrie(t, keys).Stats()
+	// depths: "" 1, "a" 3, "aa" 4, "ab" 5, "aba" 5, "b" 3, "ba" 5, "bab" 5, "bb" 4
+	exp := critbitgo.Stats{
+		Internals: 8,
+		Externals: 9,
+		KeyBytes:  16,
+		MaxDepth:  5,
+		AvgDepth:  35.0 / 9,
+		Bytes:     s.Bytes,
+	}
+	if s != exp {
+		t.Errorf("Stats() - invalid stats %+v\n%s", s, dumpTrie(buildTrie(t, keys)))
+	}
+	if s.Bytes <= s.KeyBytes {
+		t.Errorf("Stats() - invalid bytes [%d]", s.Bytes)
+	}
+
+	// only trees with WithOrderStatistics pay for the counts
+	counted := critbitgo.NewTrie(critbitgo.WithOrderStatistics())
+	for _, key := range keys {
+		counted.Insert([]byte(key), nil)
+	}
+	if c := counted.Stats(); c.Bytes != s.Bytes+c.Internals*strconv.IntSize/8 {
+		t.Errorf("Stats() - invalid bytes with counts [%d, %d]", c.Bytes, s.Bytes)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := critbitgo.NewTrie().Validate(); err != nil {
+		t.Errorf("Validate() - empty tree: %s", err)
+	}
+
+	random := rand.New(rand.NewSource(0))
+	genKey := randomKey(random, 5)
+	for _, opts := range [][]critbitgo.Option{nil, {critbitgo.WithOrderStatistics()}} {
+		trie := critbitgo.NewTrie(opts...)
+		for i := 0; i < 2000; i++ {
+			key := genKey()
+			if random.Intn(3) == 0 {
+				trie.Delete(key)
+			} else {
+				trie.Set(key, nil)
+			}
+			if err := trie.Validate(); err != nil {
+				t.Fatalf("Validate() - %s\n%s", err, dumpTrie(trie))
+			}
+		}
+	}
+
+	// breaking the tree by changing a stored key
+	trie := critbitgo.NewTrie()
+	keys := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+	for _, key := range keys {
+		trie.Insert(key, nil)
+	}
+	keys[1][0] = 'd'
+	if err := trie.Validate(); !errors.Is(err, critbitgo.ErrInvalidTree) {
+		t.Errorf("Validate() - broken tree: invalid error [%v]", err)
+	}
+}
+
 func TestKeyContainsZeroValue(t *testing.T) {
 	trie := critbitgo.NewTrie()
 	trie.Insert([]byte{1, 0, 1}, nil)
@@ -370,6 +1102,20 @@ func TestKeyContainsZeroValue(t *testing.T) {
 	trie.Allprefixed([]byte(""), handle)
 }
 
+func TestKeyContainsZeroValueAfterDelete(t *testing.T) {
+	trie := critbitgo.NewTrie()
+	trie.Insert([]byte{}, nil)
+	trie.Insert([]byte{0}, nil)
+	trie.Insert([]byte{1}, nil)
+	trie.Delete([]byte{0})
+	trie.Insert([]byte{0x40}, nil)
+	for _, key := range [][]byte{{}, {1}, {0x40}} {
+		if !trie.Contains(key) {
+			t.Errorf("Contains() - not found [%x]\n%s", key, dumpTrie(trie))
+		}
+	}
+}
+
 func TestEmptyTree(t *testing.T) {
 	trie := critbitgo.NewTrie()
 	key := []byte{0, 1, 2}
@@ -389,4 +1135,56 @@ func TestEmptyTree(t *testing.T) {
 	assert("LongestPrefix", func() { trie.LongestPrefix(key) })
 	assert("Allprefixed", func() { trie.Allprefixed(key, handle) })
 	assert("Walk", func() { trie.Walk(key, handle) })
+	assert("WalkFrom", func() { trie.WalkFrom(key, handle) })
+	assert("WalkAfter", func() { trie.WalkAfter(key, handle) })
+	assert("WalkReverse", func() { trie.WalkReverse(key, handle) })
+	assert("AllprefixedReverse", func() { trie.AllprefixedReverse(key, handle) })
+	assert("Min", func() { trie.Min() })
+	assert("Max", func() { trie.Max() })
+	assert("Floor", func() { trie.Floor(key) })
+	assert("Ceiling", func() { trie.Ceiling(key) })
+	assert("Lower", func() { trie.Lower(key) })
+	assert("Higher", func() { trie.Higher(key) })
+	assert("Rank", func() { trie.Rank(key) })
+	assert("Select", func() { trie.Select(0) })
+	assert("CountRange", func() { trie.CountRange(key, key, 0) })
+	assert("Range", func() { trie.Range(key, nil, 0, handle) })
+}
+
+func TestTrieOf(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	trie := critbitgo.NewTrieOf[int]()
+	for i, key := range keys {
+		if !trie.Insert([]byte(key), i) {
+			t.Errorf("Insert() - failed insert \"%s\"", key)
+		}
+	}
+
+	for i, key := range keys {
+		if value, ok := trie.Get([]byte(key)); !ok || value != i {
+			t.Errorf("Get() - not found - %s", key)
+		}
+	}
+	if value, ok := trie.Get([]byte("aaa")); ok || value != 0 {
+		t.Error("Get() - phantom found")
+	}
+	if key, value, ok := trie.LongestPrefix([]byte("abc")); !ok || string(key) != "ab" || value != 5 {
+		t.Errorf("LongestPrefix() - invalid result - %s, %d", key, value)
+	}
+
+	var sum int
+	trie.Allprefixed([]byte("b"), func(_ []byte, value int) bool {
+		sum += value
+		return true
+	})
+	if sum != 3+4+6+8 {
+		t.Errorf("Allprefixed() - invalid sum [%d]", sum)
+	}
+
+	if value, ok := trie.Delete([]byte("bb")); !ok || value != 4 {
+		t.Errorf("Delete() - failed - %d", value)
+	}
+	if value, ok := trie.Delete([]byte("bb")); ok || value != 0 {
+		t.Error("Delete() - phantom found")
+	}
 }
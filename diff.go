@@ -0,0 +1,190 @@
+package critbitgo
+
+import (
+	"bytes"
+	"iter"
+	"net"
+	"reflect"
+	"strconv"
+)
+
+// DiffKind is the kind of a change reported by Diff.
+type DiffKind int
+
+const (
+	// the key is only in the new tree.
+	Added DiffKind = iota + 1
+	// the key is only in the old tree.
+	Removed
+	// the key is in both trees with different values.
+	Changed
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case Added:
+		return "Added"
+	case Removed:
+		return "Removed"
+	case Changed:
+		return "Changed"
+	}
+	return "DiffKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// DiffEvent is a change of a key reported by Diff.
+// `Old` is the zero value if the key is added, and `New` is the zero value if the key is removed.
+type DiffEvent[K, V any] struct {
+	Kind DiffKind
+	Key  K
+	Old  V
+	New  V
+}
+
+// TrieView is a tree compared by Diff, which is either TrieOf or PersistentTrieOf
+// such as a Snapshot.
+type TrieView[V any] interface {
+	view() *TrieOf[V]
+}
+
+func (t *TrieOf[V]) view() *TrieOf[V] {
+	return t
+}
+
+func (t *PersistentTrieOf[V]) view() *TrieOf[V] {
+	return &t.trie
+}
+
+// Diff returns an iterator over changes from `old` to `new` in ascending order of keys.
+// Values of a key in both trees are compared by `equal`, or reflect.DeepEqual if `equal` is nil.
+// Subtrees shared by both trees, such as those of a tree and its Snapshot, are skipped without
+// being traversed, except that all keys are compared if either tree is in an arena (with WithArena).
+// Neither tree must be changed during the iteration.
+func Diff[V any](oldView, newView TrieView[V], equal func(a, b V) bool) iter.Seq[DiffEvent[[]byte, V]] {
+	old, new := oldView.view(), newView.view()
+	if equal == nil {
+		equal = func(a, b V) bool {
+			return reflect.DeepEqual(a, b)
+		}
+	}
+	return func(yield func(DiffEvent[[]byte, V]) bool) {
+		switch {
+		case old.arena != nil || new.arena != nil:
+			diffKeys(old, new, equal, yield)
+		case old.size == 0:
+			if new.size > 0 {
+				diffAll(&new.root, Added, yield)
+			}
+		case new.size == 0:
+			diffAll(&old.root, Removed, yield)
+		default:
+			diffNode(&old.root, &new.root, equal, yield)
+		}
+	}
+}
+
+// DiffNet returns an iterator over changes of routes from `old` to `new` in the order of Walk.
+// Values are compared in the same way as Diff.
+func DiffNet[V any](old, new *NetOf[V], equal func(a, b V) bool) iter.Seq[DiffEvent[*net.IPNet, V]] {
+	return func(yield func(DiffEvent[*net.IPNet, V]) bool) {
+		for family := range old.tries {
+			for e := range Diff(old.tries[family], new.tries[family], equal) {
+				if !yield(DiffEvent[*net.IPNet, V]{e.Kind, netKeyToIPNet(e.Key), e.Old, e.New}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// reporting changes from `old` to `new` by walking keys of both trees together.
+func diffKeys[V any](old, new *TrieOf[V], equal func(V, V) bool, yield func(DiffEvent[[]byte, V]) bool) {
+	next, stop := iter.Pull2(new.All())
+	defer stop()
+	kb, vb, ok := next()
+	var zero V
+	if !old.Allprefixed(nil, func(ka []byte, va V) bool {
+		for ; ok && bytes.Compare(kb, ka) < 0; kb, vb, ok = next() {
+			if !yield(DiffEvent[[]byte, V]{Added, kb, zero, vb}) {
+				return false
+			}
+		}
+		if !ok || !bytes.Equal(ka, kb) {
+			return yield(DiffEvent[[]byte, V]{Removed, ka, va, zero})
+		}
+		if !equal(va, vb) && !yield(DiffEvent[[]byte, V]{Changed, kb, va, vb}) {
+			return false
+		}
+		kb, vb, ok = next()
+		return true
+	}) {
+		return
+	}
+	for ; ok; kb, vb, ok = next() {
+		if !yield(DiffEvent[[]byte, V]{Added, kb, zero, vb}) {
+			return
+		}
+	}
+}
+
+// reporting changes from the subtree `a` to the subtree `b`.
+func diffNode[V any](a, b *node[V], equal func(V, V) bool, yield func(DiffEvent[[]byte, V]) bool) bool {
+	// shared subtrees
+	if (a.internal != nil && a.internal == b.internal) || (a.external != nil && a.external == b.external) {
+		return true
+	}
+
+	ea, eb := a.first(), b.first()
+	ka, kb := ea.key, eb.key
+	offset, bit, cont := ea.criticalBit(kb)
+	if offset == -1 && a.internal == nil && b.internal == nil {
+		if equal(a.external.value, b.external.value) {
+			return true
+		}
+		return yield(DiffEvent[[]byte, V]{Changed, b.external.key, a.external.value, b.external.value})
+	}
+
+	ia, ib := a.internal, b.internal
+	if offset != -1 {
+		crit := &critBit{offset: offset, bit: bit, cont: cont}
+		if (ia == nil || !ia.above(crit)) && (ib == nil || !ib.above(crit)) {
+			// keys of the subtrees differ before their critical bits
+			if bytes.Compare(ka, kb) < 0 {
+				return diffAll(a, Removed, yield) && diffAll(b, Added, yield)
+			}
+			return diffAll(b, Added, yield) && diffAll(a, Removed, yield)
+		}
+	}
+
+	switch {
+	case ia != nil && ib != nil && ia.above(&ib.critBit) && ib.above(&ia.critBit):
+		// the same critical bit
+		return diffNode(&ia.child[0], &ib.child[0], equal, yield) &&
+			diffNode(&ia.child[1], &ib.child[1], equal, yield)
+	case ia != nil && (ib == nil || ia.above(&ib.critBit)):
+		// all keys of `b` are in one side of `a`
+		if ia.direction(kb) == 0 {
+			return diffNode(&ia.child[0], b, equal, yield) && diffAll(&ia.child[1], Removed, yield)
+		}
+		return diffAll(&ia.child[0], Removed, yield) && diffNode(&ia.child[1], b, equal, yield)
+	default:
+		// all keys of `a` are in one side of `b`
+		if ib.direction(ka) == 0 {
+			return diffNode(a, &ib.child[0], equal, yield) && diffAll(&ib.child[1], Added, yield)
+		}
+		return diffAll(&ib.child[0], Added, yield) && diffNode(a, &ib.child[1], equal, yield)
+	}
+}
+
+// reporting all keys of the subtree as `kind`.
+func diffAll[V any](n *node[V], kind DiffKind, yield func(DiffEvent[[]byte, V]) bool) bool {
+	return allprefixed(n, func(k []byte, v V) bool {
+		e := DiffEvent[[]byte, V]{Kind: kind, Key: k}
+		if kind == Added {
+			e.New = v
+		} else {
+			e.Old = v
+		}
+		return yield(e)
+	})
+}
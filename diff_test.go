@@ -0,0 +1,135 @@
+package critbitgo_test
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/k-sone/critbitgo"
+)
+
+type diffEvent struct {
+	kind     critbitgo.DiffKind
+	key      string
+	old, new int
+}
+
+func TestDiff(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	genKey := randomKey(random, 5)
+
+	for i := 0; i < 200; i++ {
+		// all keys are compared with a tree in an arena
+		var opts []critbitgo.Option
+		arena := i%4 >= 2
+		if arena {
+			opts = append(opts, critbitgo.WithArena())
+		}
+		new := critbitgo.NewTrieOf[int](opts...)
+		for n := random.Intn(60); n > 0; n-- {
+			new.Set(genKey(), random.Intn(5))
+		}
+
+		// a tree changed from its snapshot or from an independent tree
+		var old critbitgo.TrieView[int]
+		if i%2 == 0 {
+			old = new.Snapshot()
+		} else {
+			clone := critbitgo.NewTrieOf[int]()
+			for k, v := range new.All() {
+				clone.Set(k, v)
+			}
+			old = clone
+		}
+		mo := toMap(new)
+		var sets int
+		for n := random.Intn(20); n > 0; n-- {
+			if key := genKey(); random.Intn(2) == 0 {
+				new.Delete(key)
+			} else {
+				new.Set(key, random.Intn(5))
+				sets++
+			}
+		}
+		mn := toMap(new)
+		var exp []diffEvent
+		for k, v := range mo {
+			if w, ok := mn[k]; !ok {
+				exp = append(exp, diffEvent{critbitgo.Removed, k, v, 0})
+			} else if v != w {
+				exp = append(exp, diffEvent{critbitgo.Changed, k, v, w})
+			}
+		}
+		for k, w := range mn {
+			if _, ok := mo[k]; !ok {
+				exp = append(exp, diffEvent{critbitgo.Added, k, 0, w})
+			}
+		}
+		sort.Slice(exp, func(i, j int) bool {
+			return exp[i].key < exp[j].key
+		})
+
+		var events []diffEvent
+		var compared int
+		equal := func(a, b int) bool {
+			compared++
+			return a == b
+		}
+		for e := range critbitgo.Diff(old, new, equal) {
+			events = append(events, diffEvent{e.Kind, string(e.Key), e.Old, e.New})
+		}
+		if !reflect.DeepEqual(events, exp) {
+			t.Fatalf("Diff() - invalid events\n%v\n%v", events, exp)
+		}
+		// shared keys of a snapshot are not compared
+		if i%2 == 0 && !arena && compared > sets {
+			t.Fatalf("Diff() - compared shared keys [%d, %d]", compared, sets)
+		}
+	}
+}
+
+func TestDiffEqual(t *testing.T) {
+	old := critbitgo.NewTrieOf[int]()
+	new := critbitgo.NewTrieOf[int]()
+	for i, key := range []string{"a", "ab", "b"} {
+		old.Set([]byte(key), i)
+		new.Set([]byte(key), i*10)
+	}
+	new.Set([]byte("c"), 3)
+
+	// values are equal if both are even or odd
+	var events []string
+	for e := range critbitgo.Diff(old, new, func(a, b int) bool { return a%2 == b%2 }) {
+		events = append(events, e.Kind.String()+" "+string(e.Key))
+	}
+	if exp := []string{"Changed ab", "Added c"}; !reflect.DeepEqual(events, exp) {
+		t.Errorf("Diff() - invalid events %v", events)
+	}
+
+	// aborting the iteration
+	for range critbitgo.Diff(old, new, nil) {
+		break
+	}
+}
+
+func TestDiffNet(t *testing.T) {
+	old := buildTestNet(t)
+	new := old.Snapshot()
+	new.DeleteCIDR("192.168.1.0/28")
+	new.AddCIDR("172.16.0.0/12", "172.16.0.0/12")
+	new.AddCIDR("192.168.2.1/32", "changed")
+
+	var events []string
+	for e := range critbitgo.DiffNet(old, new, nil) {
+		events = append(events, e.Kind.String()+" "+e.Key.String())
+	}
+	exp := []string{
+		"Added 172.16.0.0/12",
+		"Removed 192.168.1.0/28",
+		"Changed 192.168.2.1/32",
+	}
+	if !reflect.DeepEqual(events, exp) {
+		t.Errorf("DiffNet() - invalid events %v", events)
+	}
+}
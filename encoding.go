@@ -0,0 +1,268 @@
+package critbitgo
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// Binary format of Trie (all integers are unsigned varints):
+//
+//	+-------+---------+-------+-----------------------------------------+
+//	| magic | version | count | count * (key len, key, value len, value) |
+//	+-------+---------+-------+-----------------------------------------+
+//
+// Keys are recorded in ascending order, values are encoded by ValueCodec.
+const (
+	binaryMagic   = "CBIT"
+	binaryVersion = 1
+)
+
+// ErrInvalidData is returned when binary data is not in the format of Trie.
+var ErrInvalidData = errors.New("critbitgo: invalid binary data")
+
+// ValueCodec encodes and decodes values for MarshalBinary and UnmarshalBinary.
+type ValueCodec[V any] interface {
+	// appending the encoded value to `b`.
+	AppendValue(b []byte, value V) ([]byte, error)
+	// decoding a value encoded by AppendValue.
+	DecodeValue(data []byte) (V, error)
+}
+
+// WithValueCodec sets the codec of values used by MarshalBinary and UnmarshalBinary.
+// The type of values of the codec must be the same as the tree.
+func WithValueCodec[V any](codec ValueCodec[V]) Option {
+	return func(o *options) {
+		o.codec = codec
+	}
+}
+
+// BytesCodec is a ValueCodec for []byte values.
+type BytesCodec struct{}
+
+func (BytesCodec) AppendValue(b []byte, value []byte) ([]byte, error) {
+	return append(b, value...), nil
+}
+
+func (BytesCodec) DecodeValue(data []byte) ([]byte, error) {
+	return bytes.Clone(data), nil
+}
+
+// StringCodec is a ValueCodec for string values.
+type StringCodec struct{}
+
+func (StringCodec) AppendValue(b []byte, value string) ([]byte, error) {
+	return append(b, value...), nil
+}
+
+func (StringCodec) DecodeValue(data []byte) (string, error) {
+	return string(data), nil
+}
+
+func (t *TrieOf[V]) valueCodec() (ValueCodec[V], error) {
+	if codec, ok := t.opts.codec.(ValueCodec[V]); ok {
+		return codec, nil
+	}
+	var zero V
+	return nil, fmt.Errorf("critbitgo: no value codec for %T", zero)
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+// Values are encoded by the codec set by WithValueCodec.
+func (t *TrieOf[V]) MarshalBinary() (data []byte, err error) {
+	codec, err := t.valueCodec()
+	if err != nil {
+		return
+	}
+
+	data = append(data, binaryMagic...)
+	data = binary.AppendUvarint(data, binaryVersion)
+	data = binary.AppendUvarint(data, uint64(t.size))
+	var value []byte
+	t.Allprefixed(nil, func(k []byte, v V) bool {
+		if value, err = codec.AppendValue(value[:0], v); err != nil {
+			return false
+		}
+		data = binary.AppendUvarint(data, uint64(len(k)))
+		data = append(data, k...)
+		data = binary.AppendUvarint(data, uint64(len(value)))
+		data = append(data, value...)
+		return true
+	})
+	if err != nil {
+		data = nil
+	}
+	return
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+// It replaces all elements of the tree in linear time, since keys are recorded in ascending order.
+// Values are decoded by the codec set by WithValueCodec.
+func (t *TrieOf[V]) UnmarshalBinary(data []byte) error {
+	codec, err := t.valueCodec()
+	if err != nil {
+		return err
+	}
+
+	if !bytes.HasPrefix(data, []byte(binaryMagic)) {
+		return ErrInvalidData
+	}
+	data = data[len(binaryMagic):]
+	next := func() (n uint64) {
+		var l int
+		if n, l = binary.Uvarint(data); l <= 0 {
+			err = ErrInvalidData
+			return 0
+		}
+		data = data[l:]
+		return
+	}
+	nextBytes := func() (b []byte) {
+		if n := next(); err == nil {
+			if n > uint64(len(data)) {
+				err = ErrInvalidData
+				return
+			}
+			b, data = data[:n:n], data[n:]
+		}
+		return
+	}
+
+	if version := next(); err != nil {
+		return err
+	} else if version != binaryVersion {
+		return fmt.Errorf("critbitgo: unsupported binary version %d", version)
+	}
+	count := next()
+	if err != nil {
+		return err
+	}
+
+	// building into a new tree, so that `t` is not changed on error
+	nt := &TrieOf[V]{opts: t.opts, gen: t.gen}
+	b := newBuilder(nt)
+	for i := uint64(0); i < count; i++ {
+		key := nt.copyKey(nextBytes())
+		value := nextBytes()
+		if err != nil {
+			return err
+		}
+		var v V
+		if v, err = codec.DecodeValue(value); err != nil {
+			return err
+		}
+		if err = b.add(key, v); err != nil {
+			return err
+		}
+	}
+	if len(data) != 0 {
+		return ErrInvalidData
+	}
+	b.finish()
+	*t = *nt
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+// The map is encoded as a JSON object with keys in ascending order.
+func (m *SortedMapOf[V]) MarshalJSON() ([]byte, error) {
+	return marshalJSONObject(m.Size(), func(handle func(string, V) bool) {
+		m.Each("", handle)
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// It replaces all elements of the map by members of a JSON object.
+func (m *SortedMapOf[V]) UnmarshalJSON(data []byte) error {
+	var members map[string]V
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	if m.trie == nil {
+		m.trie = NewTrieOf[V]()
+	}
+	m.Clear()
+	for k, v := range members {
+		m.Set(k, v)
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+// The table is encoded as a JSON object keyed by routes in CIDR notation.
+func (n *NetOf[V]) MarshalJSON() ([]byte, error) {
+	return marshalJSONObject(n.Size(), func(handle func(string, V) bool) {
+		// formatting stored keys, since IPv4-mapped IPv6 routes kept by KeepMapped
+		// would be formatted as IPv4 routes by net.IPNet
+		for _, t := range n.tries {
+			if !t.Walk(nil, func(k []byte, v V) bool {
+				return handle(netKeyToPrefix(k).String(), v)
+			}) {
+				return
+			}
+		}
+	})
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// It replaces all routes of the table by members of a JSON object keyed by routes in CIDR notation.
+// If a key is not CIDR notation, returns an error and the table is not changed.
+func (n *NetOf[V]) UnmarshalJSON(data []byte) error {
+	var members map[string]V
+	if err := json.Unmarshal(data, &members); err != nil {
+		return err
+	}
+	cidrs := make([]string, 0, len(members))
+	for s := range members {
+		cidrs = append(cidrs, s)
+	}
+	sort.Strings(cidrs)
+
+	nn := &NetOf[V]{opts: n.opts}
+	for family := range nn.tries {
+		nn.tries[family] = &TrieOf[V]{opts: n.opts}
+	}
+	for _, s := range cidrs {
+		if err := nn.AddCIDR(s, members[s]); err != nil {
+			return err
+		}
+	}
+	for family, t := range nn.tries {
+		if n.tries[family] == nil {
+			n.tries[family] = t
+		} else {
+			*n.tries[family] = *t
+		}
+	}
+	return nil
+}
+
+func marshalJSONObject[V any](size int, each func(func(string, V) bool)) (data []byte, err error) {
+	if size == 0 {
+		return []byte("{}"), nil
+	}
+	var b []byte
+	data = append(data, '{')
+	each(func(k string, v V) bool {
+		if len(data) > 1 {
+			data = append(data, ',')
+		}
+		if b, err = json.Marshal(k); err != nil {
+			return false
+		}
+		data = append(data, b...)
+		data = append(data, ':')
+		if b, err = json.Marshal(v); err != nil {
+			return false
+		}
+		data = append(data, b...)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '}'), nil
+}
@@ -0,0 +1,229 @@
+package critbitgo_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/rand"
+	"net"
+	"net/netip"
+	"reflect"
+	"testing"
+
+	"github.com/k-sone/critbitgo"
+)
+
+func TestMarshalBinary(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	trie := critbitgo.NewTrieOf[string](critbitgo.WithValueCodec[string](critbitgo.StringCodec{}))
+	for _, key := range keys {
+		trie.Insert([]byte(key), key+"!")
+	}
+
+	data, err := trie.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() - error occurred %s", err)
+	}
+
+	other := critbitgo.NewTrieOf[string](critbitgo.WithValueCodec[string](critbitgo.StringCodec{}), critbitgo.WithOrderStatistics())
+	other.Insert([]byte("c"), "c")
+	if err := other.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() - error occurred %s", err)
+	}
+	if other.Size() != len(keys) || other.Contains([]byte("c")) {
+		t.Errorf("UnmarshalBinary() - invalid size [%d]", other.Size())
+	}
+	for _, key := range keys {
+		if v, ok := other.Get([]byte(key)); !ok || v != key+"!" {
+			t.Errorf("UnmarshalBinary() - not found %s", key)
+		}
+	}
+	if k, _, ok := other.Select(3); !ok || string(k) != "ab" {
+		t.Errorf("UnmarshalBinary() - invalid order statistics [%s]", k)
+	}
+
+	// same structure as Trie built by Insert
+	var d1, d2 bytes.Buffer
+	trie.Dump(&d1)
+	other.Dump(&d2)
+	if d1.String() != d2.String() {
+		t.Errorf("UnmarshalBinary() - different tries\norigin:\n%s\nother:\n%s\n", &d1, &d2)
+	}
+
+	// empty tree
+	empty := critbitgo.NewTrieOf[string](critbitgo.WithValueCodec[string](critbitgo.StringCodec{}))
+	if data, err = empty.MarshalBinary(); err != nil {
+		t.Fatalf("MarshalBinary() - error occurred %s", err)
+	}
+	if err := other.UnmarshalBinary(data); err != nil || other.Size() != 0 {
+		t.Errorf("UnmarshalBinary() - empty: %v, %d", err, other.Size())
+	}
+}
+
+func TestMarshalBinaryRandom(t *testing.T) {
+	genKey := randomKey(rand.New(rand.NewSource(0)), 8)
+	codec := critbitgo.WithValueCodec[[]byte](critbitgo.BytesCodec{})
+	trie := critbitgo.NewTrieOf[[]byte](codec)
+	for i := 0; i < 1000; i++ {
+		key := genKey()
+		trie.Set(key, key)
+	}
+	data, err := trie.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary() - error occurred %s", err)
+	}
+	other := critbitgo.NewTrieOf[[]byte](codec)
+	if err := other.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary() - error occurred %s", err)
+	}
+
+	var exp, ret [][]byte
+	for k, v := range trie.All() {
+		exp = append(exp, k, v)
+	}
+	for k, v := range other.All() {
+		ret = append(ret, k, v)
+	}
+	if !reflect.DeepEqual(exp, ret) {
+		t.Errorf("UnmarshalBinary() - expected [%x], actual [%x]", exp, ret)
+	}
+}
+
+func TestUnmarshalBinaryError(t *testing.T) {
+	trie := critbitgo.NewTrieOf[string](critbitgo.WithValueCodec[string](critbitgo.StringCodec{}))
+	trie.Insert([]byte("a"), "a")
+
+	build := func(version uint64, keys ...string) []byte {
+		data := []byte("CBIT")
+		data = binary.AppendUvarint(data, version)
+		data = binary.AppendUvarint(data, uint64(len(keys)))
+		for _, key := range keys {
+			data = binary.AppendUvarint(data, uint64(len(key)))
+			data = append(data, key...)
+			data = binary.AppendUvarint(data, 0)
+		}
+		return data
+	}
+
+	valid := build(1, "a", "b")
+	tests := []struct {
+		data []byte
+		err  error
+	}{
+		{[]byte{}, critbitgo.ErrInvalidData},
+		{[]byte("CBIX"), critbitgo.ErrInvalidData},
+		{valid[:len(valid)-1], critbitgo.ErrInvalidData},
+		{append(valid, 0), critbitgo.ErrInvalidData},
+		{build(1, "b", "a"), critbitgo.ErrUnsortedKeys},
+		{build(1, "a", "a"), critbitgo.ErrDuplicateKey},
+		{build(2, "a"), nil},
+	}
+	for i, test := range tests {
+		err := trie.UnmarshalBinary(test.data)
+		if err == nil || (test.err != nil && !errors.Is(err, test.err)) {
+			t.Errorf("UnmarshalBinary() - %d: unexpected error %v", i, err)
+		}
+	}
+	if v, ok := trie.Get([]byte("a")); !ok || v != "a" || trie.Size() != 1 {
+		t.Error("UnmarshalBinary() - changed on error")
+	}
+
+	if _, err := critbitgo.NewTrie().MarshalBinary(); err == nil {
+		t.Error("MarshalBinary() - no codec")
+	}
+}
+
+func TestSortedMapJSON(t *testing.T) {
+	m := critbitgo.NewSortedMapOf[int]()
+	for i, key := range []string{"b", "a", "ab", "", "\"q\""} {
+		m.Set(key, i)
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatalf("MarshalJSON() - error occurred %s", err)
+	}
+	if exp := `{"":3,"\"q\"":4,"a":1,"ab":2,"b":0}`; string(data) != exp {
+		t.Errorf("MarshalJSON() - expected [%s], actual [%s]", exp, data)
+	}
+
+	other := critbitgo.NewSortedMapOf[int]()
+	other.Set("c", 5)
+	if err := json.Unmarshal(data, other); err != nil {
+		t.Fatalf("UnmarshalJSON() - error occurred %s", err)
+	}
+	if !reflect.DeepEqual(m.Keys(), other.Keys()) {
+		t.Errorf("UnmarshalJSON() - invalid keys [%v]", other.Keys())
+	}
+	if v, ok := other.Get("ab"); !ok || v != 2 {
+		t.Errorf("UnmarshalJSON() - invalid value [%d]", v)
+	}
+
+	var s struct{ M *critbitgo.SortedMap }
+	if err := json.Unmarshal([]byte(`{"M":{"x":[1,2]}}`), &s); err != nil || s.M.Size() != 1 {
+		t.Errorf("UnmarshalJSON() - failed into zero value: %v", err)
+	}
+	if data, err := json.Marshal(critbitgo.NewSortedMap()); err != nil || string(data) != "{}" {
+		t.Errorf("MarshalJSON() - empty: %s, %v", data, err)
+	}
+	if err := json.Unmarshal([]byte(`{"a":"x"}`), other); err == nil {
+		t.Error("UnmarshalJSON() - not error")
+	}
+}
+
+func TestNetJSON(t *testing.T) {
+	n := buildTestNet(t)
+	data, err := json.Marshal(n)
+	if err != nil {
+		t.Fatalf("MarshalJSON() - error occurred %s", err)
+	}
+	if exp := `{"10.0.0.0/8":"10.0.0.0/8","192.168.0.0/16":"192.168.0.0/16",`; !bytes.HasPrefix(data, []byte(exp)) {
+		t.Errorf("MarshalJSON() - invalid data [%s]", data)
+	}
+
+	other := critbitgo.NewNet()
+	if err := json.Unmarshal(data, other); err != nil {
+		t.Fatalf("UnmarshalJSON() - error occurred %s", err)
+	}
+	if other.Size() != n.Size() {
+		t.Errorf("UnmarshalJSON() - invalid size [%d]", other.Size())
+	}
+	checkMatch(t, other, "192.168.1.35/32", "192.168.1.32/30")
+
+	if err := json.Unmarshal([]byte(`{"10.0.0.0/8":1,"10.0.0.0":2}`), other); err == nil {
+		t.Error("UnmarshalJSON() - not error")
+	}
+	if other.Size() != n.Size() {
+		t.Errorf("UnmarshalJSON() - changed on error [%d]", other.Size())
+	}
+
+	var s struct{ N *critbitgo.NetOf[int] }
+	if err := json.Unmarshal([]byte(`{"N":{"10.1.0.0/16":1,"2001:db8::/32":2}}`), &s); err != nil || s.N.Size() != 2 {
+		t.Errorf("UnmarshalJSON() - failed into zero value: %v", err)
+	}
+	if r, v, _ := s.N.MatchIP(net.ParseIP("2001:db8::1")); r == nil || v != 2 {
+		t.Errorf("UnmarshalJSON() - invalid route: %v, %v", r, v)
+	}
+
+	// IPv4-mapped IPv6 routes keep their family
+	mapped := critbitgo.NewNetOf[int](critbitgo.WithMappedAddrs(critbitgo.KeepMapped))
+	for i, s := range []string{"10.0.0.0/8", "::ffff:10.0.0.0/104"} {
+		if err := mapped.AddPrefix(netip.MustParsePrefix(s), i); err != nil {
+			t.Fatalf("AddPrefix() - %s: error occurred %s", s, err)
+		}
+	}
+	if data, err = json.Marshal(mapped); err != nil {
+		t.Fatalf("MarshalJSON() - error occurred %s", err)
+	}
+	if exp := `{"10.0.0.0/8":0,"::ffff:10.0.0.0/104":1}`; string(data) != exp {
+		t.Errorf("MarshalJSON() - invalid data with KeepMapped [%s]", data)
+	}
+	restored := critbitgo.NewNetOf[int](critbitgo.WithMappedAddrs(critbitgo.KeepMapped))
+	if err := json.Unmarshal(data, restored); err != nil {
+		t.Fatalf("UnmarshalJSON() - error occurred %s", err)
+	}
+	if restored.FamilySize(critbitgo.IPv4) != 1 || restored.FamilySize(critbitgo.IPv6) != 1 {
+		t.Errorf("UnmarshalJSON() - invalid families with KeepMapped %d, %d",
+			restored.FamilySize(critbitgo.IPv4), restored.FamilySize(critbitgo.IPv6))
+	}
+}
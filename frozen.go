@@ -0,0 +1,363 @@
+package critbitgo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// Layout of FrozenTrie (all integers are little endian):
+//
+//	header    : magic(4) version(u32) size(u64) root(u32) reserved(u32)
+//	            internals offset(u64) externals offset(u64) data offset(u64) data length(u64)
+//	internals : (size - 1) * { offset(u32) bit(u8) cont(u8) reserved(u16) child[0](u32) child[1](u32) }
+//	externals : size * { data offset(u64) key length(u32) value length(u32) }
+//	data      : key and value of each external node
+//
+// A child refers to an external node if its most significant bit is set, or an internal node otherwise.
+// Internal nodes are stored before their children, and external nodes are stored in ascending order of keys.
+const (
+	frozenMagic        = "CBFZ"
+	frozenVersion      = 1
+	frozenHeaderSize   = 56
+	frozenInternalSize = 16
+	frozenExternalSize = 16
+	frozenExternalFlag = 1 << 31
+)
+
+// ErrInvalidFrozenData is returned when data is not in the layout of FrozenTrie.
+var ErrInvalidFrozenData = errors.New("critbitgo: invalid frozen data")
+
+// Read-only crit-bit tree queried in place from data written by WriteFrozen,
+// such as a memory-mapped file opened by OpenFrozenTrie.
+// Keys and values are slices of the data, they must not be modified.
+// It is safe for concurrent use.
+type FrozenTrie struct {
+	data      []byte
+	size      int
+	root      uint32
+	internals []byte
+	externals []byte
+	closer    func() error
+}
+
+// write the tree in the layout of FrozenTrie.
+// Values are encoded by the codec set by WithValueCodec.
+func (t *TrieOf[V]) WriteFrozen(w io.Writer) error {
+	codec, err := t.valueCodec()
+	if err != nil {
+		return err
+	}
+	if uint64(t.size) > frozenExternalFlag {
+		return errors.New("critbitgo: too many keys to freeze")
+	}
+
+	var internals, externals, data []byte
+	// appending the record of an internal node, whose children are frozen by `child`
+	freezeInternal := func(crit *critBit, child func(i int) uint32) uint32 {
+		if err != nil {
+			return 0
+		}
+		i := len(internals) / frozenInternalSize
+		internals = append(internals, make([]byte, frozenInternalSize)...)
+		rec := internals[i*frozenInternalSize:]
+		binary.LittleEndian.PutUint32(rec[0:], uint32(crit.offset))
+		rec[4] = crit.bit
+		if crit.cont {
+			rec[5] = 1
+		}
+		c0 := child(0)
+		c1 := child(1)
+		rec = internals[i*frozenInternalSize:]
+		binary.LittleEndian.PutUint32(rec[8:], c0)
+		binary.LittleEndian.PutUint32(rec[12:], c1)
+		return uint32(i)
+	}
+	// appending the record of an external node
+	freezeExternal := func(key []byte, value V) uint32 {
+		if err != nil {
+			return 0
+		}
+		i := len(externals) / frozenExternalSize
+		off := len(data)
+		data = append(data, key...)
+		if data, err = codec.AppendValue(data, value); err != nil {
+			return 0
+		}
+		if uint64(len(key)) > math.MaxUint32 || uint64(len(data)-off-len(key)) > math.MaxUint32 {
+			err = errors.New("critbitgo: too large element to freeze")
+			return 0
+		}
+		externals = binary.LittleEndian.AppendUint64(externals, uint64(off))
+		externals = binary.LittleEndian.AppendUint32(externals, uint32(len(key)))
+		externals = binary.LittleEndian.AppendUint32(externals, uint32(len(data)-off-len(key)))
+		return uint32(i) | frozenExternalFlag
+	}
+
+	var root uint32
+	if a := t.arena; a != nil {
+		var freeze func(ref uint32) uint32
+		freeze = func(ref uint32) uint32 {
+			if ref&arenaExternalFlag != 0 {
+				return freezeExternal(a.key(ref), a.value(ref))
+			}
+			in := &a.internals[ref]
+			return freezeInternal(&in.critBit, func(i int) uint32 {
+				return freeze(in.child[i])
+			})
+		}
+		root = freeze(a.root)
+	} else if t.size > 0 {
+		var freeze func(n *node[V]) uint32
+		freeze = func(n *node[V]) uint32 {
+			if in := n.internal; in != nil {
+				return freezeInternal(&in.critBit, func(i int) uint32 {
+					return freeze(&in.child[i])
+				})
+			}
+			return freezeExternal(n.external.key, n.external.value)
+		}
+		root = freeze(&t.root)
+	}
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 0, frozenHeaderSize)
+	header = append(header, frozenMagic...)
+	header = binary.LittleEndian.AppendUint32(header, frozenVersion)
+	header = binary.LittleEndian.AppendUint64(header, uint64(t.size))
+	header = binary.LittleEndian.AppendUint32(header, root)
+	header = binary.LittleEndian.AppendUint32(header, 0)
+	off := uint64(frozenHeaderSize)
+	header = binary.LittleEndian.AppendUint64(header, off)
+	off += uint64(len(internals))
+	header = binary.LittleEndian.AppendUint64(header, off)
+	off += uint64(len(externals))
+	header = binary.LittleEndian.AppendUint64(header, off)
+	header = binary.LittleEndian.AppendUint64(header, uint64(len(data)))
+
+	bw := bufio.NewWriter(w)
+	for _, b := range [][]byte{header, internals, externals, data} {
+		if _, err = bw.Write(b); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// create a read-only tree from data written by WriteFrozen.
+// The data is used in place, it must not be modified while the tree is used.
+// Only the header is checked here, nodes referring out of the data are treated as not found when accessed.
+func NewFrozenTrie(data []byte) (*FrozenTrie, error) {
+	if len(data) < frozenHeaderSize || !bytes.HasPrefix(data, []byte(frozenMagic)) {
+		return nil, ErrInvalidFrozenData
+	}
+	if binary.LittleEndian.Uint32(data[4:]) != frozenVersion {
+		return nil, errors.New("critbitgo: unsupported frozen version")
+	}
+	size := binary.LittleEndian.Uint64(data[8:])
+	ioff := binary.LittleEndian.Uint64(data[24:])
+	eoff := binary.LittleEndian.Uint64(data[32:])
+	doff := binary.LittleEndian.Uint64(data[40:])
+	dlen := binary.LittleEndian.Uint64(data[48:])
+	root := binary.LittleEndian.Uint32(data[16:])
+
+	var isize uint64
+	if size > 0 {
+		isize = (size - 1) * frozenInternalSize
+	}
+	if size > frozenExternalFlag || ioff != frozenHeaderSize || eoff != ioff+isize ||
+		doff != eoff+size*frozenExternalSize || doff > uint64(len(data)) || dlen > uint64(len(data))-doff {
+		return nil, ErrInvalidFrozenData
+	}
+	// the root is the first node
+	if (size == 1 && root != frozenExternalFlag) || (size > 1 && root != 0) {
+		return nil, ErrInvalidFrozenData
+	}
+	return &FrozenTrie{
+		data:      data[doff : doff+dlen],
+		size:      int(size),
+		root:      root,
+		internals: data[ioff:eoff],
+		externals: data[eoff:doff],
+	}, nil
+}
+
+// Open a file written by WriteFrozen as a read-only tree.
+// The file is memory-mapped where supported, Close must be called to release it.
+func OpenFrozenTrie(path string) (*FrozenTrie, error) {
+	data, closer, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := NewFrozenTrie(data)
+	if err != nil {
+		closer()
+		return nil, err
+	}
+	f.closer = closer
+	return f, nil
+}
+
+// Release the file opened by OpenFrozenTrie.
+// The tree, and keys and values returned from it, must not be used after Close.
+func (f *FrozenTrie) Close() (err error) {
+	if f.closer != nil {
+		err = f.closer()
+		f.closer = nil
+	}
+	*f = FrozenTrie{}
+	return
+}
+
+// return the number of key in a tree.
+func (f *FrozenTrie) Size() int {
+	return f.size
+}
+
+// calculate direction.
+// if the child is invalid, `ok` is false.
+func (f *FrozenTrie) direction(i uint32, key []byte) (child uint32, ok bool) {
+	rec := f.internals[int(i)*frozenInternalSize : int(i+1)*frozenInternalSize]
+	offset := int(binary.LittleEndian.Uint32(rec[0:]))
+	if offset < len(key) && (key[offset]&rec[4] != 0 || rec[5] != 0) {
+		return f.child(i, 1)
+	}
+	return f.child(i, 0)
+}
+
+// return a child of the internal node `i`.
+// A child must be stored after its parent, so that walking corrupted data terminates.
+// if the child is invalid, `ok` is false.
+func (f *FrozenTrie) child(i uint32, direction int) (child uint32, ok bool) {
+	child = binary.LittleEndian.Uint32(f.internals[int(i)*frozenInternalSize+8+direction*4:])
+	if child&frozenExternalFlag != 0 {
+		return child, int(child&^frozenExternalFlag) < f.size
+	}
+	return child, child > i && int(child) < f.size-1
+}
+
+// if the key or the value is out of the data, `ok` is false.
+func (f *FrozenTrie) external(ref uint32) (key, value []byte, ok bool) {
+	rec := f.externals[int(ref&^frozenExternalFlag)*frozenExternalSize:]
+	off := binary.LittleEndian.Uint64(rec[0:])
+	klen := uint64(binary.LittleEndian.Uint32(rec[8:]))
+	vlen := uint64(binary.LittleEndian.Uint32(rec[12:]))
+	if off > uint64(len(f.data)) || klen+vlen > uint64(len(f.data))-off {
+		return nil, nil, false
+	}
+	return f.data[off : off+klen : off+klen], f.data[off+klen : off+klen+vlen : off+klen+vlen], true
+}
+
+// searching the tree.
+// if the data is corrupted, `ok` is false.
+func (f *FrozenTrie) search(key []byte) (ref uint32, ok bool) {
+	ref, ok = f.root, true
+	for ok && ref&frozenExternalFlag == 0 {
+		ref, ok = f.direction(ref, key)
+	}
+	return
+}
+
+// membership testing.
+func (f *FrozenTrie) Contains(key []byte) bool {
+	_, ok := f.Get(key)
+	return ok
+}
+
+// get member.
+// if `key` is in Trie, `ok` is true.
+func (f *FrozenTrie) Get(key []byte) (value []byte, ok bool) {
+	if f.size == 0 {
+		return
+	}
+	if ref, ok := f.search(key); ok {
+		if k, v, ok := f.external(ref); ok && bytes.Equal(k, key) {
+			return v, true
+		}
+	}
+	return
+}
+
+// Search for the longest matching key from the beginning of the given key.
+// if `key` is in Trie, `ok` is true.
+func (f *FrozenTrie) LongestPrefix(given []byte) (key []byte, value []byte, ok bool) {
+	if f.size == 0 {
+		return
+	}
+	return f.longestPrefix(f.root, given)
+}
+
+func (f *FrozenTrie) longestPrefix(ref uint32, key []byte) ([]byte, []byte, bool) {
+	if ref&frozenExternalFlag == 0 {
+		child, ok := f.direction(ref, key)
+		if !ok {
+			return nil, nil, false
+		}
+		if k, v, ok := f.longestPrefix(child, key); ok {
+			return k, v, ok
+		}
+		if c0, ok := f.child(ref, 0); ok && child != c0 {
+			return f.longestPrefix(c0, key)
+		}
+	} else {
+		if k, v, ok := f.external(ref); ok && bytes.HasPrefix(key, k) {
+			return k, v, true
+		}
+	}
+	return nil, nil, false
+}
+
+// fetching elements with a given prefix.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+// Elements in corrupted data are skipped.
+func (f *FrozenTrie) Allprefixed(prefix []byte, handle func(key, value []byte) bool) bool {
+	// an empty tree
+	if f.size == 0 {
+		return true
+	}
+
+	// walk tree, maintaining top pointer
+	p := f.root
+	top := p
+	ok := true
+	if len(prefix) > 0 {
+		for ok && p&frozenExternalFlag == 0 {
+			q := p
+			p, ok = f.direction(q, prefix)
+			if int(binary.LittleEndian.Uint32(f.internals[int(q)*frozenInternalSize:])) < len(prefix) {
+				top = p
+			}
+		}
+
+		// check prefix
+		if !ok {
+			return true
+		}
+		if k, _, ok := f.external(p); !ok || !bytes.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+
+	// external nodes of a subtree are stored consecutively in ascending order
+	first, last := top, top
+	for ok && first&frozenExternalFlag == 0 {
+		first, ok = f.child(first, 0)
+	}
+	for ok && last&frozenExternalFlag == 0 {
+		last, ok = f.child(last, 1)
+	}
+	if !ok {
+		return true
+	}
+	for ref := first; ref <= last; ref++ {
+		if k, v, ok := f.external(ref); ok && !handle(k, v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,176 @@
+package critbitgo_test
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/k-sone/critbitgo"
+)
+
+func buildFrozenTrie(t *testing.T, trie *critbitgo.TrieOf[[]byte]) *critbitgo.FrozenTrie {
+	path := filepath.Join(t.TempDir(), "frozen")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trie.WriteFrozen(f); err != nil {
+		t.Fatalf("WriteFrozen() - error occurred %s", err)
+	}
+	f.Close()
+
+	frozen, err := critbitgo.OpenFrozenTrie(path)
+	if err != nil {
+		t.Fatalf("OpenFrozenTrie() - error occurred %s", err)
+	}
+	t.Cleanup(func() { frozen.Close() })
+	return frozen
+}
+
+func TestFrozenTrie(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	trie := critbitgo.NewTrieOf[[]byte](critbitgo.WithValueCodec[[]byte](critbitgo.BytesCodec{}))
+	for _, key := range keys {
+		trie.Insert([]byte(key), []byte(key+"!"))
+	}
+	frozen := buildFrozenTrie(t, trie)
+
+	if s := frozen.Size(); s != len(keys) {
+		t.Errorf("Size() - expected [%d], actual [%d]", len(keys), s)
+	}
+	for _, key := range keys {
+		if value, ok := frozen.Get([]byte(key)); !ok || string(value) != key+"!" {
+			t.Errorf("Get() - not found - %s", key)
+		}
+		if !frozen.Contains([]byte(key)) {
+			t.Errorf("Contains() - not found - %s", key)
+		}
+	}
+	if frozen.Contains([]byte("aaa")) {
+		t.Error("Contains() - phantom found")
+	}
+	if key, value, ok := frozen.LongestPrefix([]byte("abc")); !ok || string(key) != "ab" || string(value) != "ab!" {
+		t.Errorf("LongestPrefix() - invalid result - %s", key)
+	}
+
+	var elems []string
+	handle := func(key, value []byte) bool {
+		elems = append(elems, string(key))
+		return true
+	}
+	if !frozen.Allprefixed([]byte("a"), handle) {
+		t.Error("Allprefixed() - invalid result")
+	}
+	if exp := []string{"a", "aa", "ab", "aba"}; !reflect.DeepEqual(elems, exp) {
+		t.Errorf("Allprefixed() - invalid elems [%v]", elems)
+	}
+}
+
+func TestFrozenTrieRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	genKey := randomKey(random, 5)
+
+	trie := critbitgo.NewTrieOf[[]byte](critbitgo.WithValueCodec[[]byte](critbitgo.BytesCodec{}))
+	for i := 0; i < 500; i++ {
+		key := genKey()
+		trie.Set(key, append(key, 'v'))
+	}
+	frozen := buildFrozenTrie(t, trie)
+
+	for i := 0; i < 500; i++ {
+		key := genKey()
+		v1, ok1 := trie.Get(key)
+		v2, ok2 := frozen.Get(key)
+		if ok1 != ok2 || !bytes.Equal(v1, v2) {
+			t.Fatalf("Get() - %x: expected [%x], actual [%x]", key, v1, v2)
+		}
+		k1, _, ok1 := trie.LongestPrefix(key)
+		k2, _, ok2 := frozen.LongestPrefix(key)
+		if ok1 != ok2 || !bytes.Equal(k1, k2) {
+			t.Fatalf("LongestPrefix() - %x: expected [%x], actual [%x]", key, k1, k2)
+		}
+
+		var exp, ret [][]byte
+		trie.Allprefixed(key, func(k, v []byte) bool {
+			exp = append(exp, k, v)
+			return true
+		})
+		frozen.Allprefixed(key, func(k, v []byte) bool {
+			ret = append(ret, k, v)
+			return true
+		})
+		if !reflect.DeepEqual(exp, ret) {
+			t.Fatalf("Allprefixed() - %x: expected [%x], actual [%x]", key, exp, ret)
+		}
+	}
+}
+
+func TestFrozenTrieEmpty(t *testing.T) {
+	trie := critbitgo.NewTrieOf[[]byte](critbitgo.WithValueCodec[[]byte](critbitgo.BytesCodec{}))
+	frozen := buildFrozenTrie(t, trie)
+	key := []byte{0, 1, 2}
+	if frozen.Size() != 0 || frozen.Contains(key) {
+		t.Error("Contains() - phantom found")
+	}
+	if _, _, ok := frozen.LongestPrefix(key); ok {
+		t.Error("LongestPrefix() - phantom found")
+	}
+	if !frozen.Allprefixed(nil, func(_, _ []byte) bool { return false }) {
+		t.Error("Allprefixed() - invalid result")
+	}
+
+	var buf bytes.Buffer
+	trie.Insert(key, nil)
+	trie.WriteFrozen(&buf)
+	data := buf.Bytes()
+	for _, d := range [][]byte{nil, data[:len(data)-10], append([]byte("XXXX"), data[4:]...)} {
+		if _, err := critbitgo.NewFrozenTrie(d); err == nil {
+			t.Error("NewFrozenTrie() - not error")
+		}
+	}
+	if _, err := critbitgo.OpenFrozenTrie(filepath.Join(t.TempDir(), "none")); err == nil {
+		t.Error("OpenFrozenTrie() - not error")
+	}
+}
+
+func TestFrozenTrieCorrupted(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	genKey := randomKey(random, 5)
+
+	trie := critbitgo.NewTrieOf[[]byte](critbitgo.WithValueCodec[[]byte](critbitgo.BytesCodec{}))
+	for _, key := range []string{"hello", "help", "world", "word"} {
+		trie.Insert([]byte(key), []byte(key))
+	}
+	var buf bytes.Buffer
+	if err := trie.WriteFrozen(&buf); err != nil {
+		t.Fatalf("WriteFrozen() - error occurred %s", err)
+	}
+	data := buf.Bytes()
+
+	// the length of the data is recorded in the header
+	for n := 1; n <= 5; n++ {
+		if _, err := critbitgo.NewFrozenTrie(data[:len(data)-n]); err != critbitgo.ErrInvalidFrozenData {
+			t.Errorf("NewFrozenTrie() - truncated %d bytes: invalid error [%v]", n, err)
+		}
+	}
+
+	// nodes referring out of the data are not found, the header is 56 bytes
+	for i := 0; i < 1000; i++ {
+		d := append([]byte(nil), data...)
+		for n := random.Intn(4) + 1; n > 0; n-- {
+			d[56+random.Intn(len(d)-56)] = byte(random.Intn(256))
+		}
+		frozen, err := critbitgo.NewFrozenTrie(d)
+		if err != nil {
+			t.Fatalf("NewFrozenTrie() - error occurred %s", err)
+		}
+		for _, key := range [][]byte{[]byte("help"), []byte("word"), genKey()} {
+			frozen.Get(key)
+			frozen.LongestPrefix(key)
+			frozen.Allprefixed(key[:len(key)/2], func(_, _ []byte) bool { return true })
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package critbitgo
+
+import (
+	"iter"
+	"net"
+	"net/netip"
+)
+
+// All returns an iterator over all keys and values in ascending order.
+func (t *TrieOf[V]) All() iter.Seq2[[]byte, V] {
+	return func(yield func([]byte, V) bool) {
+		t.Allprefixed(nil, yield)
+	}
+}
+
+// Prefixed returns an iterator over keys that have a given prefix in ascending order.
+func (t *TrieOf[V]) Prefixed(prefix []byte) iter.Seq2[[]byte, V] {
+	return func(yield func([]byte, V) bool) {
+		t.Allprefixed(prefix, yield)
+	}
+}
+
+// From returns an iterator over keys from the first key that is equal to or greater than
+// a given start key in ascending order.
+func (t *TrieOf[V]) From(start []byte) iter.Seq2[[]byte, V] {
+	return func(yield func([]byte, V) bool) {
+		t.WalkFrom(start, yield)
+	}
+}
+
+// Backward returns an iterator over all keys and values in descending order.
+func (t *TrieOf[V]) Backward() iter.Seq2[[]byte, V] {
+	return func(yield func([]byte, V) bool) {
+		t.WalkReverse(nil, yield)
+	}
+}
+
+// All returns an iterator over all keys and values in ascending order.
+func (t *PersistentTrieOf[V]) All() iter.Seq2[[]byte, V] {
+	return t.trie.All()
+}
+
+// Prefixed returns an iterator over keys that have a given prefix in ascending order.
+func (t *PersistentTrieOf[V]) Prefixed(prefix []byte) iter.Seq2[[]byte, V] {
+	return t.trie.Prefixed(prefix)
+}
+
+// From returns an iterator over keys from the first key that is equal to or greater than
+// a given start key in ascending order.
+func (t *PersistentTrieOf[V]) From(start []byte) iter.Seq2[[]byte, V] {
+	return t.trie.From(start)
+}
+
+// Backward returns an iterator over all keys and values in descending order.
+func (t *PersistentTrieOf[V]) Backward() iter.Seq2[[]byte, V] {
+	return t.trie.Backward()
+}
+
+// All returns an iterator over all elements in ascending order.
+func (m *SortedMapOf[V]) All() iter.Seq2[string, V] {
+	return m.Prefixed("")
+}
+
+// Prefixed returns an iterator over elements that have a given prefix in ascending order.
+func (m *SortedMapOf[V]) Prefixed(prefix string) iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		m.Each(prefix, yield)
+	}
+}
+
+// From returns an iterator over elements from the first key that is equal to or greater than
+// a given start key in ascending order.
+func (m *SortedMapOf[V]) From(start string) iter.Seq2[string, V] {
+	return stringKeys(m.trie.From([]byte(start)))
+}
+
+// Backward returns an iterator over all elements in descending order.
+func (m *SortedMapOf[V]) Backward() iter.Seq2[string, V] {
+	return stringKeys(m.trie.Backward())
+}
+
+func stringKeys[V any](seq iter.Seq2[[]byte, V]) iter.Seq2[string, V] {
+	return func(yield func(string, V) bool) {
+		for k, v := range seq {
+			if !yield(string(k), v) {
+				return
+			}
+		}
+	}
+}
+
+// All returns an iterator over all routes in ascending order.
+func (n *NetOf[V]) All() iter.Seq2[*net.IPNet, V] {
+	return n.From(nil)
+}
+
+// Prefixed returns an iterator over routes that have a given prefix in ascending order.
+// The prefix is handled in the same way as WalkPrefix.
+func (n *NetOf[V]) Prefixed(r *net.IPNet) iter.Seq2[*net.IPNet, V] {
+	return func(yield func(*net.IPNet, V) bool) {
+		n.WalkPrefix(r, yield)
+	}
+}
+
+// From returns an iterator over routes from the first route that is equal to or greater than
+// a given route in ascending order.
+func (n *NetOf[V]) From(r *net.IPNet) iter.Seq2[*net.IPNet, V] {
+	return func(yield func(*net.IPNet, V) bool) {
+		n.WalkFrom(r, yield)
+	}
+}
+
+// Backward returns an iterator over all routes in descending order.
+func (n *NetOf[V]) Backward() iter.Seq2[*net.IPNet, V] {
+	return func(yield func(*net.IPNet, V) bool) {
+		n.WalkReverse(nil, yield)
+	}
+}
+
+// Prefixes returns an iterator over all routes as prefixes in ascending order.
+func (n *NetOf[V]) Prefixes() iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		n.WalkFromPrefix(netip.Prefix{}, yield)
+	}
+}
+
+// PrefixesWithin returns an iterator over routes within a given prefix in ascending order.
+// The prefix is handled in the same way as WalkWithinPrefix.
+func (n *NetOf[V]) PrefixesWithin(p netip.Prefix) iter.Seq2[netip.Prefix, V] {
+	return func(yield func(netip.Prefix, V) bool) {
+		n.WalkWithinPrefix(p, yield)
+	}
+}
@@ -0,0 +1,139 @@
+package critbitgo_test
+
+import (
+	"net"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestTrieIter(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	trie := buildTrie(t, keys)
+
+	collect := func(seq func(func([]byte, interface{}) bool)) []string {
+		elems := []string{}
+		for k, v := range seq {
+			if string(k) != v {
+				t.Errorf("invalid value [%s](%v)", k, v)
+			}
+			elems = append(elems, string(k))
+		}
+		return elems
+	}
+
+	exp := []string{"", "a", "aa", "ab", "aba", "b", "ba", "bab", "bb"}
+	if elems := collect(trie.All()); !reflect.DeepEqual(elems, exp) {
+		t.Errorf("All() - invalid elems [%v]", elems)
+	}
+	if elems := collect(trie.Prefixed([]byte("a"))); !reflect.DeepEqual(elems, exp[1:5]) {
+		t.Errorf("Prefixed() - invalid elems [%v]", elems)
+	}
+	if elems := collect(trie.From([]byte("ab"))); !reflect.DeepEqual(elems, exp[3:]) {
+		t.Errorf("From() - invalid elems [%v]", elems)
+	}
+	rexp := slices.Clone(exp)
+	slices.Reverse(rexp)
+	if elems := collect(trie.Backward()); !reflect.DeepEqual(elems, rexp) {
+		t.Errorf("Backward() - invalid elems [%v]", elems)
+	}
+
+	// early exit
+	elems := []string{}
+	for k := range trie.All() {
+		if string(k) == "ab" {
+			break
+		}
+		elems = append(elems, string(k))
+	}
+	if !reflect.DeepEqual(elems, exp[:3]) {
+		t.Errorf("All() - invalid elems on break [%v]", elems)
+	}
+}
+
+func TestSortedMapIter(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	m := buildSortedMap(keys)
+
+	exp := []string{"", "a", "aa", "ab", "aba", "b", "ba", "bab", "bb"}
+	var elems []string
+	for k, v := range m.All() {
+		if k != v {
+			t.Errorf("All() - invalid value [%s](%v)", k, v)
+		}
+		elems = append(elems, k)
+	}
+	if !reflect.DeepEqual(elems, exp) {
+		t.Errorf("All() - invalid elems [%v]", elems)
+	}
+
+	elems = elems[:0]
+	for k := range m.Prefixed("b") {
+		elems = append(elems, k)
+	}
+	if !reflect.DeepEqual(elems, exp[5:]) {
+		t.Errorf("Prefixed() - invalid elems [%v]", elems)
+	}
+
+	elems = elems[:0]
+	for k := range m.From("aba") {
+		elems = append(elems, k)
+	}
+	if !reflect.DeepEqual(elems, exp[4:]) {
+		t.Errorf("From() - invalid elems [%v]", elems)
+	}
+
+	elems = elems[:0]
+	for k := range m.Backward() {
+		elems = append(elems, k)
+		if k == "b" {
+			break
+		}
+	}
+	if !reflect.DeepEqual(elems, []string{"bb", "bab", "ba", "b"}) {
+		t.Errorf("Backward() - invalid elems [%v]", elems)
+	}
+}
+
+func TestNetIter(t *testing.T) {
+	trie := buildTestNet(t)
+
+	var ret []string
+	for r, v := range trie.All() {
+		if r.String() != v {
+			t.Errorf("All() - invalid value [%s](%v)", r, v)
+		}
+		ret = append(ret, r.String())
+	}
+	if len(ret) != 11 || ret[0] != "10.0.0.0/8" || ret[10] != "192.168.2.2/32" {
+		t.Errorf("All() - failed %s", ret)
+	}
+
+	ret = ret[:0]
+	for r := range trie.Backward() {
+		ret = append(ret, r.String())
+	}
+	if len(ret) != 11 || ret[0] != "192.168.2.2/32" || ret[10] != "10.0.0.0/8" {
+		t.Errorf("Backward() - failed %s", ret)
+	}
+
+	ret = ret[:0]
+	_, s, _ := net.ParseCIDR("192.168.1.32/27")
+	for r := range trie.From(s) {
+		ret = append(ret, r.String())
+	}
+	exp := []string{"192.168.1.32/27", "192.168.1.32/30", "192.168.2.1/32", "192.168.2.2/32"}
+	if !reflect.DeepEqual(ret, exp) {
+		t.Errorf("From() - failed %s", ret)
+	}
+
+	ret = ret[:0]
+	_, s, _ = net.ParseCIDR("192.168.2.0/24")
+	for r := range trie.Prefixed(s) {
+		ret = append(ret, r.String())
+	}
+	exp = []string{"192.168.2.1/32", "192.168.2.2/32"}
+	if !reflect.DeepEqual(ret, exp) {
+		t.Errorf("Prefixed() - failed %s", ret)
+	}
+}
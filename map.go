@@ -5,38 +5,42 @@ import (
 )
 
 // The map is sorted according to the natural ordering of its keys
-type SortedMap struct {
-	trie *Trie
+// It is not safe for concurrent use.
+type SortedMapOf[V any] struct {
+	trie *TrieOf[V]
 }
 
-func (m *SortedMap) Contains(key string) bool {
+// The map holding values of any type.
+type SortedMap = SortedMapOf[interface{}]
+
+func (m *SortedMapOf[V]) Contains(key string) bool {
 	return m.trie.Contains(*(*[]byte)(unsafe.Pointer(&key)))
 }
 
-func (m *SortedMap) Get(key string) (value interface{}, ok bool) {
+func (m *SortedMapOf[V]) Get(key string) (value V, ok bool) {
 	return m.trie.Get(*(*[]byte)(unsafe.Pointer(&key)))
 }
 
-func (m *SortedMap) Set(key string, value interface{}) {
+func (m *SortedMapOf[V]) Set(key string, value V) {
 	m.trie.Set([]byte(key), value)
 }
 
-func (m *SortedMap) Delete(key string) (value interface{}, ok bool) {
+func (m *SortedMapOf[V]) Delete(key string) (value V, ok bool) {
 	return m.trie.Delete(*(*[]byte)(unsafe.Pointer(&key)))
 }
 
-func (m *SortedMap) Clear() {
+func (m *SortedMapOf[V]) Clear() {
 	m.trie.Clear()
 }
 
-func (m *SortedMap) Size() int {
+func (m *SortedMapOf[V]) Size() int {
 	return m.trie.Size()
 }
 
 // Returns a slice of sorted keys
-func (m *SortedMap) Keys() []string {
+func (m *SortedMapOf[V]) Keys() []string {
 	keys := make([]string, 0, m.Size())
-	m.trie.Allprefixed([]byte{}, func(k []byte, v interface{}) bool {
+	m.trie.Allprefixed([]byte{}, func(k []byte, v V) bool {
 		keys = append(keys, string(k))
 		return true
 	})
@@ -45,13 +49,140 @@ func (m *SortedMap) Keys() []string {
 
 // Executes a provided function for each element that has a given prefix.
 // if handle returns `false`, the iteration is aborted.
-func (m *SortedMap) Each(prefix string, handle func(key string, value interface{}) bool) bool {
-	return m.trie.Allprefixed([]byte(prefix), func(k []byte, v interface{}) bool {
+func (m *SortedMapOf[V]) Each(prefix string, handle func(key string, value V) bool) bool {
+	return m.trie.Allprefixed([]byte(prefix), func(k []byte, v V) bool {
 		return handle(string(k), v)
 	})
 }
 
+// Executes a provided function for each element that has a given prefix in reverse order.
+// if handle returns `false`, the iteration is aborted.
+func (m *SortedMapOf[V]) EachReverse(prefix string, handle func(key string, value V) bool) bool {
+	return m.trie.AllprefixedReverse([]byte(prefix), func(k []byte, v V) bool {
+		return handle(string(k), v)
+	})
+}
+
+// Executes a provided function for each element in reverse order from the last key that is
+// equal to or less than a given start key.
+// if handle returns `false`, the iteration is aborted.
+func (m *SortedMapOf[V]) WalkReverse(start string, handle func(key string, value V) bool) bool {
+	return m.trie.WalkReverse([]byte(start), func(k []byte, v V) bool {
+		return handle(string(k), v)
+	})
+}
+
+// Executes a provided function for each element between `lo` and `hi`.
+// The ends of the range are handled in the same way as Trie.Range.
+// if handle returns `false`, the iteration is aborted.
+func (m *SortedMapOf[V]) Range(lo, hi string, mode RangeMode, handle func(key string, value V) bool) bool {
+	return m.trie.Range([]byte(lo), []byte(hi), mode, func(k []byte, v V) bool {
+		return handle(string(k), v)
+	})
+}
+
+// Returns the smallest key in the map.
+func (m *SortedMapOf[V]) Min() (key string, value V, ok bool) {
+	return stringKey(m.trie.Min())
+}
+
+// Returns the largest key in the map.
+func (m *SortedMapOf[V]) Max() (key string, value V, ok bool) {
+	return stringKey(m.trie.Max())
+}
+
+// Returns the largest key equal to or less than a given key.
+func (m *SortedMapOf[V]) Floor(given string) (key string, value V, ok bool) {
+	return stringKey(m.trie.Floor(*(*[]byte)(unsafe.Pointer(&given))))
+}
+
+// Returns the smallest key equal to or greater than a given key.
+func (m *SortedMapOf[V]) Ceiling(given string) (key string, value V, ok bool) {
+	return stringKey(m.trie.Ceiling(*(*[]byte)(unsafe.Pointer(&given))))
+}
+
+// Returns the largest key strictly less than a given key.
+func (m *SortedMapOf[V]) Lower(given string) (key string, value V, ok bool) {
+	return stringKey(m.trie.Lower(*(*[]byte)(unsafe.Pointer(&given))))
+}
+
+// Returns the smallest key strictly greater than a given key.
+func (m *SortedMapOf[V]) Higher(given string) (key string, value V, ok bool) {
+	return stringKey(m.trie.Higher(*(*[]byte)(unsafe.Pointer(&given))))
+}
+
+func stringKey[V any](k []byte, v V, ok bool) (string, V, bool) {
+	return string(k), v, ok
+}
+
+// Returns a deep copy of the map.
+func (m *SortedMapOf[V]) Clone() *SortedMapOf[V] {
+	return &SortedMapOf[V]{m.trie.Clone()}
+}
+
+// Returns a point-in-time copy of the map in O(1).
+// The copy shares nodes with the original in the same way as Trie.Snapshot,
+// except that both can be changed, copying shared nodes before changing them.
+func (m *SortedMapOf[V]) Snapshot() *SortedMapOf[V] {
+	return &SortedMapOf[V]{m.trie.snapshot()}
+}
+
+// Returns a new map holding keys in either map.
+// Values of keys in both maps are resolved by `merge` in the same way as Trie.Union.
+func (m *SortedMapOf[V]) Union(other *SortedMapOf[V], merge func(key string, a, b V) V) *SortedMapOf[V] {
+	var f func([]byte, V, V) V
+	if merge != nil {
+		f = func(k []byte, a, b V) V {
+			return merge(string(k), a, b)
+		}
+	}
+	return &SortedMapOf[V]{m.trie.Union(other.trie, f)}
+}
+
+// Returns a new map holding keys in both maps.
+// Values are resolved by `merge` in the same way as Trie.Intersect.
+func (m *SortedMapOf[V]) Intersect(other *SortedMapOf[V], merge func(key string, a, b V) V) *SortedMapOf[V] {
+	var f func([]byte, V, V) V
+	if merge != nil {
+		f = func(k []byte, a, b V) V {
+			return merge(string(k), a, b)
+		}
+	}
+	return &SortedMapOf[V]{m.trie.Intersect(other.trie, f)}
+}
+
+// Returns a new map holding keys in the map but not in `other`.
+func (m *SortedMapOf[V]) Difference(other *SortedMapOf[V]) *SortedMapOf[V] {
+	return &SortedMapOf[V]{m.trie.Difference(other.trie)}
+}
+
+// Returns a new map holding keys in either map but not in both.
+func (m *SortedMapOf[V]) SymmetricDifference(other *SortedMapOf[V]) *SortedMapOf[V] {
+	return &SortedMapOf[V]{m.trie.SymmetricDifference(other.trie)}
+}
+
+// Returns the number of keys less than a given key.
+func (m *SortedMapOf[V]) Rank(key string) int {
+	return m.trie.Rank(*(*[]byte)(unsafe.Pointer(&key)))
+}
+
+// Returns the element at a given index in sorted order (starting from 0).
+func (m *SortedMapOf[V]) Select(i int) (key string, value V, ok bool) {
+	return stringKey(m.trie.Select(i))
+}
+
+// Returns the number of keys between `lo` and `hi`.
+// The ends of the range are handled in the same way as Trie.Range.
+func (m *SortedMapOf[V]) CountRange(lo, hi string, mode RangeMode) int {
+	return m.trie.CountRange([]byte(lo), []byte(hi), mode)
+}
+
 // Create a SortedMap
-func NewSortedMap() *SortedMap {
-	return &SortedMap{NewTrie()}
+func NewSortedMap(opts ...Option) *SortedMap {
+	return NewSortedMapOf[interface{}](opts...)
+}
+
+// Create a SortedMap holding values of type V.
+func NewSortedMapOf[V any](opts ...Option) *SortedMapOf[V] {
+	return &SortedMapOf[V]{NewTrieOf[V](opts...)}
 }
@@ -1,6 +1,7 @@
 package critbitgo_test
 
 import (
+	"reflect"
 	"strings"
 	"testing"
 
@@ -162,3 +163,240 @@ func TestSortedMapEach(t *testing.T) {
 		}
 	}
 }
+
+func TestSortedMapEachReverse(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	m := buildSortedMap(keys)
+
+	var elems []string
+	handle := func(key string, value interface{}) bool {
+		elems = append(elems, key)
+		return true
+	}
+	if !m.EachReverse("b", handle) {
+		t.Error("EachReverse() - invalid result")
+	}
+	if exp := []string{"bb", "bab", "ba", "b"}; !reflect.DeepEqual(elems, exp) {
+		t.Errorf("EachReverse() - invalid elems [%v]", elems)
+	}
+}
+
+func TestSortedMapWalkReverse(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	m := buildSortedMap(keys)
+
+	var elems []string
+	handle := func(key string, value interface{}) bool {
+		elems = append(elems, key)
+		return len(elems) < 4
+	}
+	if m.WalkReverse("baa", handle) {
+		t.Error("WalkReverse() - invalid result")
+	}
+	if exp := []string{"ba", "b", "aba", "ab"}; !reflect.DeepEqual(elems, exp) {
+		t.Errorf("WalkReverse() - invalid elems [%v]", elems)
+	}
+
+	elems = nil
+	if !m.WalkReverse("a", handle) {
+		t.Error("WalkReverse() - invalid result")
+	}
+	if exp := []string{"a", ""}; !reflect.DeepEqual(elems, exp) {
+		t.Errorf("WalkReverse() - invalid elems [%v]", elems)
+	}
+}
+
+func TestSortedMapNavigation(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	m := buildSortedMap(keys)
+
+	if key, value, ok := m.Min(); !ok || key != "" || value != "" {
+		t.Errorf("Min() - invalid result - %s", key)
+	}
+	if key, value, ok := m.Max(); !ok || key != "bb" || value != "bb" {
+		t.Errorf("Max() - invalid result - %s", key)
+	}
+	if key, _, ok := m.Floor("abc"); !ok || key != "aba" {
+		t.Errorf("Floor() - invalid result - %s", key)
+	}
+	if key, _, ok := m.Ceiling("abc"); !ok || key != "b" {
+		t.Errorf("Ceiling() - invalid result - %s", key)
+	}
+	if key, _, ok := m.Lower("a"); !ok || key != "" {
+		t.Errorf("Lower() - invalid result - %s", key)
+	}
+	if key, _, ok := m.Higher(""); !ok || key != "a" {
+		t.Errorf("Higher() - invalid result - %s", key)
+	}
+	if _, _, ok := m.Lower(""); ok {
+		t.Error("Lower() - phantom found")
+	}
+	if _, _, ok := m.Higher("bb"); ok {
+		t.Error("Higher() - phantom found")
+	}
+}
+
+func TestSortedMapOrderStatistics(t *testing.T) {
+	m := critbitgo.NewSortedMap(critbitgo.WithOrderStatistics())
+	for _, key := range []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"} {
+		m.Set(key, key)
+	}
+
+	if r := m.Rank("b"); r != 5 {
+		t.Errorf("Rank() - invalid result [%d]", r)
+	}
+	if key, value, ok := m.Select(3); !ok || key != "ab" || value != "ab" {
+		t.Errorf("Select() - invalid result [%s]", key)
+	}
+	if c := m.CountRange("a", "b", 0); c != 4 {
+		t.Errorf("CountRange() - invalid result [%d]", c)
+	}
+	if c := m.CountRange("b", "", critbitgo.UnboundedUpper); c != 4 {
+		t.Errorf("CountRange() - invalid result without upper bound [%d]", c)
+	}
+
+	m.Delete("aa")
+	if r := m.Rank("b"); r != 4 {
+		t.Errorf("Rank() - invalid result after delete [%d]", r)
+	}
+	if key, _, ok := m.Select(3); !ok || key != "aba" {
+		t.Errorf("Select() - invalid result after delete [%s]", key)
+	}
+}
+
+func TestSortedMapRange(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	m := buildSortedMap(keys)
+
+	var elems []string
+	handle := func(key string, value interface{}) bool {
+		elems = append(elems, key)
+		return true
+	}
+	if !m.Range("ab", "bab", 0, handle) {
+		t.Error("Range() - invalid result")
+	}
+	if exp := []string{"ab", "aba", "b", "ba"}; !reflect.DeepEqual(elems, exp) {
+		t.Errorf("Range() - invalid elems [%v]", elems)
+	}
+
+	elems = nil
+	if !m.Range("ab", "bab", critbitgo.ExcludeLower|critbitgo.IncludeUpper, handle) {
+		t.Error("Range() - invalid result")
+	}
+	if exp := []string{"aba", "b", "ba", "bab"}; !reflect.DeepEqual(elems, exp) {
+		t.Errorf("Range() - invalid elems [%v]", elems)
+	}
+
+	elems = nil
+	if !m.Range("b", "", critbitgo.UnboundedUpper, handle) {
+		t.Error("Range() - invalid result")
+	}
+	if exp := []string{"b", "ba", "bab", "bb"}; !reflect.DeepEqual(elems, exp) {
+		t.Errorf("Range() - invalid elems without upper bound [%v]", elems)
+	}
+}
+
+func TestSortedMapSnapshot(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	m := buildSortedMap(keys)
+	snapshot := m.Snapshot()
+	clone := m.Clone()
+
+	m.Set("a", 100)
+	m.Delete("bb")
+	m.Set("c", "c")
+	for _, s := range []*critbitgo.SortedMap{snapshot, clone} {
+		if !reflect.DeepEqual(s.Keys(), buildSortedMap(keys).Keys()) {
+			t.Errorf("Snapshot() - changed [%v]", s.Keys())
+		}
+		if v, _ := s.Get("a"); v != "a" {
+			t.Errorf("Snapshot() - changed value [%v]", v)
+		}
+	}
+	if v, _ := m.Get("a"); v != 100 || m.Contains("bb") || !m.Contains("c") {
+		t.Error("Snapshot() - original not changed")
+	}
+}
+
+func TestNewSortedMapFromSorted(t *testing.T) {
+	keys := []string{"", "a", "aa", "ab", "aba", "b", "ba", "bab", "bb"}
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = key
+	}
+	m, err := critbitgo.NewSortedMapFromSorted(keys, values)
+	if err != nil {
+		t.Fatalf("NewSortedMapFromSorted() - error occurred %s", err)
+	}
+	if !reflect.DeepEqual(m.Keys(), keys) {
+		t.Errorf("NewSortedMapFromSorted() - invalid keys [%v]", m.Keys())
+	}
+	for _, key := range keys {
+		if v, ok := m.Get(key); !ok || v != key {
+			t.Errorf("Get() - %q: invalid value [%v]", key, v)
+		}
+	}
+
+	if _, err := critbitgo.NewSortedMapFromSorted([]string{"b", "a"}, nil); err != critbitgo.ErrUnsortedKeys {
+		t.Errorf("NewSortedMapFromSorted() - unsorted: invalid error [%v]", err)
+	}
+	if _, err := critbitgo.NewSortedMapFromSorted([]string{"a", "a"}, nil); err != critbitgo.ErrDuplicateKey {
+		t.Errorf("NewSortedMapFromSorted() - duplicate: invalid error [%v]", err)
+	}
+}
+
+func TestSortedMapSetOperations(t *testing.T) {
+	a := buildSortedMap([]string{"", "a", "aa", "ab", "b"})
+	b := buildSortedMap([]string{"a", "ab", "aba", "c"})
+	concat := func(key string, x, y interface{}) interface{} {
+		return x.(string) + y.(string)
+	}
+
+	tests := []struct {
+		m    *critbitgo.SortedMap
+		keys []string
+	}{
+		{a.Union(b, concat), []string{"", "a", "aa", "ab", "aba", "b", "c"}},
+		{a.Intersect(b, concat), []string{"a", "ab"}},
+		{a.Difference(b), []string{"", "aa", "b"}},
+		{a.SymmetricDifference(b), []string{"", "aa", "aba", "b", "c"}},
+	}
+	for i, test := range tests {
+		if keys := test.m.Keys(); !reflect.DeepEqual(keys, test.keys) {
+			t.Errorf("set operation %d - invalid keys [%v]", i, keys)
+		}
+	}
+	if v, _ := tests[0].m.Get("ab"); v != "abab" {
+		t.Errorf("Union() - invalid merged value [%v]", v)
+	}
+	if v, _ := tests[1].m.Get("a"); v != "aa" {
+		t.Errorf("Intersect() - invalid merged value [%v]", v)
+	}
+	if a.Size() != 5 || b.Size() != 4 {
+		t.Error("set operation - operand changed")
+	}
+}
+
+func TestSortedMapOf(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	m := critbitgo.NewSortedMapOf[int]()
+	for _, key := range keys {
+		m.Set(key, len(key))
+	}
+
+	for _, key := range keys {
+		if value, ok := m.Get(key); !ok || value != len(key) {
+			t.Errorf("Get() - not found - [%s]", key)
+		}
+	}
+
+	var sum int
+	m.Each("a", func(_ string, value int) bool {
+		sum += value
+		return true
+	})
+	if sum != 1+2+2+3 {
+		t.Errorf("Each() - invalid sum [%d]", sum)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package critbitgo
+
+import (
+	"os"
+)
+
+// reading a file into memory, since mmap is not supported.
+func mapFile(path string) (data []byte, closer func() error, err error) {
+	if data, err = os.ReadFile(path); err != nil {
+		return
+	}
+	return data, func() error { return nil }, nil
+}
@@ -1,54 +1,209 @@
 package critbitgo
 
 import (
+	"errors"
+	"math/bits"
 	"net"
+	"strconv"
+	"strings"
 )
 
 var (
-	mask32  = net.IPMask{0xff, 0xff, 0xff, 0xff}
-	mask128 = net.IPMask{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	// ErrInvalidIP is returned when an IP address is not IPv4/IPv6, a route is not CIDR notation,
+	// or an IPv4-mapped IPv6 address is rejected by RejectMapped.
+	ErrInvalidIP = errors.New("critbitgo: invalid IP address")
+	// ErrNilNetwork is returned when a route is nil.
+	ErrNilNetwork = errors.New("critbitgo: IP network is nil")
+	// ErrInvalidMask is returned when a mask is not IPv4/IPv6 mask, a prefix length is out of range,
+	// or a route is rejected by RejectHostBits.
+	ErrInvalidMask = errors.New("critbitgo: invalid IP mask")
+	// ErrNotFound is returned when a route to start a walk is not found.
+	ErrNotFound = errors.New("critbitgo: route not found")
+	// ErrInvalidFamily is returned when a family is neither IPv4 nor IPv6.
+	ErrInvalidFamily = errors.New("critbitgo: invalid address family")
 )
 
+// NetError is an error about an address or a route returned by Net.
+// It wraps one of ErrInvalidIP, ErrNilNetwork, ErrInvalidMask, ErrNotFound and ErrInvalidFamily,
+// which can be tested by errors.Is.
+type NetError struct {
+	Err    error  // one of the errors above
+	Addr   string // the address or the route, empty for ErrInvalidFamily
+	Reason string // details of the error, if any
+}
+
+func (e *NetError) Error() string {
+	s := e.Err.Error()
+	if e.Reason != "" {
+		s += " (" + e.Reason + ")"
+	}
+	if e.Addr != "" {
+		s += ": " + e.Addr
+	}
+	return s
+}
+
+func (e *NetError) Unwrap() error {
+	return e.Err
+}
+
 // IP routing table.
-type Net struct {
-	trie *Trie
+// It is not safe for concurrent use, see ConcurrentNetOf.
+//
+// IPv4 and IPv6 routes are kept in separate tables, and walks iterate IPv4 routes before IPv6 routes.
+// IPv4-mapped IPv6 addresses are handled according to WithMappedAddrs, and host bits of routes
+// according to WithHostBits.
+//
+// Routes given to methods are copied. The IP of routes returned by methods and passed to handle functions
+// shares memory with the table: they may be retained, but must not be modified.
+type NetOf[V any] struct {
+	tries [2]*TrieOf[V] // tables of IPv4 and IPv6 routes
+	opts  options
+}
+
+// IP routing table holding values of any type.
+type Net = NetOf[interface{}]
+
+// Family is the address family of routes.
+type Family int
+
+const (
+	IPv4 Family = iota
+	IPv6
+)
+
+func (f Family) valid() bool {
+	return f == IPv4 || f == IPv6
+}
+
+// MappedPolicy is how Net handles IPv4-mapped IPv6 addresses such as ::ffff:10.0.0.0/104.
+type MappedPolicy int
+
+const (
+	// UnmapMapped handles mapped networks of /96 or longer as IPv4 networks, which is the default.
+	// Shorter ones are not IPv4 networks, and are handled as IPv6 networks.
+	UnmapMapped MappedPolicy = iota
+	// KeepMapped handles mapped networks as IPv6 networks.
+	KeepMapped
+	// RejectMapped returns an error for mapped networks.
+	RejectMapped
+)
+
+// WithMappedAddrs sets how Net handles IPv4-mapped IPv6 addresses given as netip.Addr, netip.Prefix
+// or *net.IPNet with a 128-bit mask.
+// A net.IP without a mask is always IPv4 if it has a 4-byte form, because net.IP does not distinguish
+// IPv4-mapped addresses, e.g. net.IPv4 returns them.
+func WithMappedAddrs(policy MappedPolicy) Option {
+	return func(o *options) {
+		o.mapped = policy
+	}
+}
+
+// HostBitsPolicy is how Net handles routes which have host bits set, such as 10.0.0.5/8,
+// or non-canonical masks, such as 255.0.255.0.
+type HostBitsPolicy int
+
+const (
+	// MaskHostBits clears host bits of routes, which is the default (lenient mode).
+	// A non-canonical mask is reduced to its leading ones.
+	MaskHostBits HostBitsPolicy = iota
+	// RejectHostBits returns an error for routes which have host bits set or
+	// non-canonical masks (strict mode).
+	RejectHostBits
+)
+
+// WithHostBits sets how Net handles host bits and masks of routes given to methods adding, getting
+// and deleting a specific route.
+// Host bits of routes given to methods matching routes are always ignored.
+func WithHostBits(policy HostBitsPolicy) Option {
+	return func(o *options) {
+		o.hostBits = policy
+	}
+}
+
+// returning the table of the family of `key`.
+func (n *NetOf[V]) table(key []byte) *TrieOf[V] {
+	return n.tries[netKeyFamily(key)]
+}
+
+// returning the key of a route.
+// If `route` is true, `r` is a specific route and its host bits are handled according to WithHostBits.
+// If `r` is not IPv4/IPv6 network, returns an error.
+func (n *NetOf[V]) ipNetKey(r *net.IPNet, route bool) ([]byte, error) {
+	ip, isV4, err := netValidateIPNet(r)
+	if err != nil {
+		return nil, err
+	}
+	ones, bits := r.Mask.Size()
+	if bits == 0 {
+		// a non-canonical mask
+		if n.opts.hostBits == RejectHostBits || (len(r.Mask) != net.IPv4len && len(r.Mask) != net.IPv6len) {
+			return nil, &NetError{Err: ErrInvalidMask, Addr: r.String(), Reason: "non-canonical mask"}
+		}
+		ones, bits = netMaskLeadingOnes(r.Mask), 8*len(r.Mask)
+	}
+	if isV4 && bits == 8*net.IPv6len {
+		// an IPv4-mapped IPv6 network
+		switch {
+		case n.opts.mapped == RejectMapped:
+			return nil, &NetError{Err: ErrInvalidIP, Addr: r.String(), Reason: "IPv4-mapped IPv6 address"}
+		case n.opts.mapped == KeepMapped || ones < 96:
+			ip = ip.To16()
+		default:
+			ones, bits = ones-96, bits-96
+		}
+	}
+	if n.opts.hostBits == RejectHostBits && bits != 8*len(ip) {
+		return nil, &NetError{Err: ErrInvalidMask, Addr: r.String(), Reason: "mask length differs from address"}
+	}
+	key := netIPNetToKey(ip, ones)
+	if route && !n.clearHostBits(key) {
+		return nil, &NetError{Err: ErrInvalidMask, Addr: r.String(), Reason: "host bits set"}
+	}
+	return key, nil
+}
+
+// clearing host bits of the key of a specific route, returning false if they are rejected.
+func (n *NetOf[V]) clearHostBits(key []byte) bool {
+	return !netKeyClearHostBits(key) || n.opts.hostBits != RejectHostBits
 }
 
 // Add a route.
 // If `r` is not IPv4/IPv6 network, returns an error.
-func (n *Net) Add(r *net.IPNet, value interface{}) (err error) {
-	var ip net.IP
-	if ip, _, err = netValidateIPNet(r); err == nil {
-		n.trie.Set(netIPNetToKey(ip, r.Mask), value)
+func (n *NetOf[V]) Add(r *net.IPNet, value V) error {
+	key, err := n.ipNetKey(r, true)
+	if err != nil {
+		return err
 	}
-	return
+	n.table(key).Set(key, value)
+	return nil
 }
 
 // Add a route.
 // If `s` is not CIDR notation, returns an error.
-func (n *Net) AddCIDR(s string, value interface{}) (err error) {
+func (n *NetOf[V]) AddCIDR(s string, value V) (err error) {
 	var r *net.IPNet
-	if _, r, err = net.ParseCIDR(s); err == nil {
-		n.Add(r, value)
+	if r, err = netParseCIDR(s); err == nil {
+		err = n.Add(r, value)
 	}
 	return
 }
 
 // Delete a specific route.
 // If `r` is not IP4/IPv6 network or a route is not found, `ok` is false.
-func (n *Net) Delete(r *net.IPNet) (value interface{}, ok bool, err error) {
-	var ip net.IP
-	if ip, _, err = netValidateIPNet(r); err == nil {
-		value, ok = n.trie.Delete(netIPNetToKey(ip, r.Mask))
+func (n *NetOf[V]) Delete(r *net.IPNet) (value V, ok bool, err error) {
+	var key []byte
+	if key, err = n.ipNetKey(r, true); err == nil {
+		value, ok = n.table(key).Delete(key)
 	}
 	return
 }
 
 // Delete a specific route.
 // If `s` is not CIDR notation or a route is not found, `ok` is false.
-func (n *Net) DeleteCIDR(s string) (value interface{}, ok bool, err error) {
+func (n *NetOf[V]) DeleteCIDR(s string) (value V, ok bool, err error) {
 	var r *net.IPNet
-	if _, r, err = net.ParseCIDR(s); err == nil {
+	if r, err = netParseCIDR(s); err == nil {
 		value, ok, err = n.Delete(r)
 	}
 	return
@@ -56,19 +211,19 @@ func (n *Net) DeleteCIDR(s string) (value interface{}, ok bool, err error) {
 
 // Get a specific route.
 // If `r` is not IPv4/IPv6 network or a route is not found, `ok` is false.
-func (n *Net) Get(r *net.IPNet) (value interface{}, ok bool, err error) {
-	var ip net.IP
-	if ip, _, err = netValidateIPNet(r); err == nil {
-		value, ok = n.trie.Get(netIPNetToKey(ip, r.Mask))
+func (n *NetOf[V]) Get(r *net.IPNet) (value V, ok bool, err error) {
+	var key []byte
+	if key, err = n.ipNetKey(r, true); err == nil {
+		value, ok = n.table(key).Get(key)
 	}
 	return
 }
 
 // Get a specific route.
 // If `s` is not CIDR notation or a route is not found, `ok` is false.
-func (n *Net) GetCIDR(s string) (value interface{}, ok bool, err error) {
+func (n *NetOf[V]) GetCIDR(s string) (value V, ok bool, err error) {
 	var r *net.IPNet
-	if _, r, err = net.ParseCIDR(s); err == nil {
+	if r, err = netParseCIDR(s); err == nil {
 		value, ok, err = n.Get(r)
 	}
 	return
@@ -76,10 +231,10 @@ func (n *Net) GetCIDR(s string) (value interface{}, ok bool, err error) {
 
 // Return a specific route by using the longest prefix matching.
 // If `r` is not IPv4/IPv6 network or a route is not found, `route` is nil.
-func (n *Net) Match(r *net.IPNet) (route *net.IPNet, value interface{}, err error) {
-	var ip net.IP
-	if ip, _, err = netValidateIP(r.IP); err == nil {
-		if k, v := n.match(netIPNetToKey(ip, r.Mask)); k != nil {
+func (n *NetOf[V]) Match(r *net.IPNet) (route *net.IPNet, value V, err error) {
+	var key []byte
+	if key, err = n.ipNetKey(r, false); err == nil {
+		if k, v := n.match(key); k != nil {
 			route = netKeyToIPNet(k)
 			value = v
 		}
@@ -89,16 +244,16 @@ func (n *Net) Match(r *net.IPNet) (route *net.IPNet, value interface{}, err erro
 
 // Return a specific route by using the longest prefix matching.
 // If `s` is not CIDR notation, or a route is not found, `route` is nil.
-func (n *Net) MatchCIDR(s string) (route *net.IPNet, value interface{}, err error) {
+func (n *NetOf[V]) MatchCIDR(s string) (route *net.IPNet, value V, err error) {
 	var r *net.IPNet
-	if _, r, err = net.ParseCIDR(s); err == nil {
+	if r, err = netParseCIDR(s); err == nil {
 		route, value, err = n.Match(r)
 	}
 	return
 }
 
 // Return a bool indicating whether a route would be found
-func (n *Net) ContainedIP(ip net.IP) (contained bool, err error) {
+func (n *NetOf[V]) ContainedIP(ip net.IP) (contained bool, err error) {
 	k, _, err := n.matchIP(ip)
 	contained = k != nil
 	return
@@ -106,7 +261,7 @@ func (n *Net) ContainedIP(ip net.IP) (contained bool, err error) {
 
 // Return a specific route by using the longest prefix matching.
 // If `ip` is invalid IP, or a route is not found, `route` is nil.
-func (n *Net) MatchIP(ip net.IP) (route *net.IPNet, value interface{}, err error) {
+func (n *NetOf[V]) MatchIP(ip net.IP) (route *net.IPNet, value V, err error) {
 	k, v, err := n.matchIP(ip)
 	if k != nil {
 		route = netKeyToIPNet(k)
@@ -115,32 +270,30 @@ func (n *Net) MatchIP(ip net.IP) (route *net.IPNet, value interface{}, err error
 	return
 }
 
-func (n *Net) matchIP(ip net.IP) (k []byte, v interface{}, err error) {
-	var isV4 bool
-	ip, isV4, err = netValidateIP(ip)
+func (n *NetOf[V]) matchIP(ip net.IP) (k []byte, v V, err error) {
+	ip, _, err = netValidateIP(ip)
 	if err != nil {
 		return
 	}
-	var mask net.IPMask
-	if isV4 {
-		mask = mask32
-	} else {
-		mask = mask128
-	}
-	k, v = n.match(netIPNetToKey(ip, mask))
+	k, v = n.match(netIPNetToKey(ip, 8*len(ip)))
 	return
 }
 
-func (n *Net) match(key []byte) ([]byte, interface{}) {
-	if n.trie.size > 0 {
-		if node := lookup(&n.trie.root, key, false); node != nil {
+func (n *NetOf[V]) match(key []byte) (k []byte, v V) {
+	t := n.table(key)
+	if a := t.arena; a != nil {
+		if ref, ok := a.lookup(a.root, key, false); ok {
+			return a.key(ref), a.value(ref)
+		}
+	} else if t.size > 0 {
+		if node := lookup(&t.root, key, false); node != nil {
 			return node.external.key, node.external.value
 		}
 	}
-	return nil, nil
+	return
 }
 
-func lookup(p *node, key []byte, backtracking bool) *node {
+func lookup[V any](p *node[V], key []byte, backtracking bool) *node[V] {
 	if p.internal != nil {
 		var direction int
 		if p.internal.offset == len(key)-1 {
@@ -161,65 +314,158 @@ func lookup(p *node, key []byte, backtracking bool) *node {
 		}
 		return nil
 	} else {
-		nlen := len(p.external.key)
-		if nlen != len(key) {
+		if len(p.external.key) != len(key) || !netRouteMatches(p.external.key, key) {
 			return nil
 		}
+		return p
+	}
+}
 
-		// check mask
-		mask := p.external.key[nlen-1]
-		if mask > key[nlen-1] {
-			return nil
-		}
+// whether the route `route` matches the route `key` of the same family.
+func netRouteMatches(route, key []byte) bool {
+	// check mask
+	mask := route[len(route)-1]
+	if mask > key[len(key)-1] {
+		return false
+	}
 
-		// compare both keys with mask
-		div := int(mask >> 3)
-		for i := 0; i < div; i++ {
-			if p.external.key[i] != key[i] {
-				return nil
-			}
+	// compare both keys with mask
+	div := int(mask >> 3)
+	for i := 0; i < div; i++ {
+		if route[i] != key[i] {
+			return false
 		}
-		if mod := uint(mask & 0x07); mod > 0 {
-			bit := 8 - mod
-			if p.external.key[div] != key[div]&(0xff>>bit<<bit) {
-				return nil
-			}
+	}
+	if mod := uint(mask & 0x07); mod > 0 {
+		bit := 8 - mod
+		if route[div] != key[div]&(0xff>>bit<<bit) {
+			return false
 		}
-		return p
 	}
+	return true
 }
 
 // Walk iterates routes from a given route.
+// If `r` is nil, the iteration starts from the first route.
+// If `r` is not IPv4/IPv6 network or not in the table, returns an error.
 // handle is called with arguments route and value (if handle returns `false`, the iteration is aborted)
-func (n *Net) Walk(r *net.IPNet, handle func(*net.IPNet, interface{}) bool) {
-	var key []byte
-	if r != nil {
-		if ip, _, err := netValidateIPNet(r); err == nil {
-			key = netIPNetToKey(ip, r.Mask)
+func (n *NetOf[V]) Walk(r *net.IPNet, handle func(*net.IPNet, V) bool) error {
+	key, err := n.walkKey(r, true)
+	if err != nil {
+		return err
+	}
+	if key != nil && !n.table(key).Contains(key) {
+		return &NetError{Err: ErrNotFound, Addr: r.String()}
+	}
+	n.walk(key, (*TrieOf[V]).Walk, netIPNetHandle(handle))
+	return nil
+}
+
+// WalkFrom iterates routes from the first route that is equal to or greater than a given route.
+// Unlike Walk, `r` does not need to be in the table.
+// If `r` is not IPv4/IPv6 network, returns an error.
+// handle is called with arguments route and value (if handle returns `false`, the iteration is aborted)
+func (n *NetOf[V]) WalkFrom(r *net.IPNet, handle func(*net.IPNet, V) bool) error {
+	key, err := n.walkKey(r, false)
+	if err == nil {
+		n.walk(key, (*TrieOf[V]).WalkFrom, netIPNetHandle(handle))
+	}
+	return err
+}
+
+// WalkAfter iterates routes from the first route that is greater than a given route.
+// If `r` is not IPv4/IPv6 network, returns an error.
+// handle is called with arguments route and value (if handle returns `false`, the iteration is aborted)
+func (n *NetOf[V]) WalkAfter(r *net.IPNet, handle func(*net.IPNet, V) bool) error {
+	key, err := n.walkKey(r, false)
+	if err == nil {
+		n.walk(key, (*TrieOf[V]).WalkAfter, netIPNetHandle(handle))
+	}
+	return err
+}
+
+// walking the table of the family of `key` by `walk`, and then the following tables from the first route.
+// If `key` is nil, walking all tables.
+func (n *NetOf[V]) walk(key []byte, walk func(*TrieOf[V], []byte, func([]byte, V) bool) bool, handle func([]byte, V) bool) {
+	var family int
+	if key != nil {
+		family = netKeyFamily(key)
+		if !walk(n.tries[family], key, handle) {
+			return
 		}
+		family++
 	}
-	n.trie.Walk(key, func(key []byte, value interface{}) bool {
-		return handle(netKeyToIPNet(key), value)
-	})
+	for ; family < len(n.tries); family++ {
+		if !n.tries[family].Walk(nil, handle) {
+			return
+		}
+	}
+}
+
+// WalkReverse iterates routes in reverse order from the last route that is equal to or less than a given route.
+// If `r` is nil, the iteration starts from the last route.
+// If `r` is not IPv4/IPv6 network, returns an error.
+// handle is called with arguments route and value (if handle returns `false`, the iteration is aborted)
+func (n *NetOf[V]) WalkReverse(r *net.IPNet, handle func(*net.IPNet, V) bool) error {
+	key, err := n.walkKey(r, false)
+	if err != nil {
+		return err
+	}
+	wrapper := netIPNetHandle(handle)
+	family := len(n.tries) - 1
+	if key != nil {
+		family = netKeyFamily(key)
+		if !n.tries[family].WalkReverse(key, wrapper) {
+			return nil
+		}
+		family--
+	}
+	for ; family >= 0; family-- {
+		if !n.tries[family].WalkReverse(nil, wrapper) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// WalkFamily iterates routes of a given family.
+// If `f` is neither IPv4 nor IPv6, returns an error wrapping ErrInvalidFamily.
+// handle is called with arguments route and value (if handle returns `false`, the iteration is aborted)
+func (n *NetOf[V]) WalkFamily(f Family, handle func(*net.IPNet, V) bool) error {
+	if !f.valid() {
+		return &NetError{Err: ErrInvalidFamily, Reason: "family " + strconv.Itoa(int(f))}
+	}
+	n.tries[f].Walk(nil, netIPNetHandle(handle))
+	return nil
+}
+
+// returning the key of a route to start a walk, which is nil if `r` is nil.
+func (n *NetOf[V]) walkKey(r *net.IPNet, route bool) ([]byte, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return n.ipNetKey(r, route)
 }
 
 // WalkPrefix interates routes that have a given prefix.
+// If `r` is nil, all routes are iterated.
+// If `r` is not IPv4/IPv6 network, returns an error.
 // handle is called with arguments route and value (if handle returns `false`, the iteration is aborted)
-func (n *Net) WalkPrefix(r *net.IPNet, handle func(*net.IPNet, interface{}) bool) {
-	var prefix []byte
+func (n *NetOf[V]) WalkPrefix(r *net.IPNet, handle func(*net.IPNet, V) bool) error {
+	prefix, err := n.walkKey(r, false)
+	if err != nil {
+		return err
+	}
 	var div int
 	var bit uint
-	if r != nil {
-		if ip, _, err := netValidateIPNet(r); err == nil {
-			prefix = netIPNetToKey(ip, r.Mask)
-			mask := prefix[len(prefix)-1]
-			div = int(mask >> 3)
-			if mod := uint(mask & 0x07); mod != 0 {
-				bit = 8 - mod
-			}
+	if prefix != nil {
+		mask := prefix[len(prefix)-1]
+		div = int(mask >> 3)
+		if mod := uint(mask & 0x07); mod != 0 {
+			bit = 8 - mod
 		}
 	}
-	wrapper := func(key []byte, value interface{}) bool {
+	wrapper := func(key []byte, value V) bool {
 		if bit != 0 {
 			if prefix[div]>>bit != key[div]>>bit {
 				return false
@@ -227,10 +473,19 @@ func (n *Net) WalkPrefix(r *net.IPNet, handle func(*net.IPNet, interface{}) bool
 		}
 		return handle(netKeyToIPNet(key), value)
 	}
-	n.trie.Allprefixed(prefix[0:div], wrapper)
+	if prefix == nil {
+		for _, t := range n.tries {
+			if !t.Allprefixed(nil, wrapper) {
+				break
+			}
+		}
+		return nil
+	}
+	n.table(prefix).Allprefixed(prefix[0:div], wrapper)
+	return nil
 }
 
-func walkMatch(p *node, key []byte, handle func(*net.IPNet, interface{}) bool) bool {
+func walkMatch[V any](p *node[V], key []byte, handle func([]byte, V) bool) bool {
 	if p.internal != nil {
 		if !walkMatch(&p.internal.child[0], key, handle) {
 			return false
@@ -242,48 +497,93 @@ func walkMatch(p *node, key []byte, handle func(*net.IPNet, interface{}) bool) b
 		return true
 	}
 
-	mask := p.external.key[len(p.external.key)-1]
-	if key[len(key)-1] < mask {
+	if !netRouteMatches(p.external.key, key) {
 		return true
 	}
+	return handle(p.external.key, p.external.value)
+}
 
-	div := int(mask >> 3)
-	for i := 0; i < div; i++ {
-		if p.external.key[i] != key[i] {
-			return true
-		}
-	}
-
-	if mod := uint(mask & 0x07); mod > 0 {
-		bit := 8 - mod
-		if p.external.key[div] != key[div]&(0xff>>bit<<bit) {
-			return true
-		}
+// iterating routes of the table matching the route `key`, from the shortest mask.
+func (t *TrieOf[V]) walkMatch(key []byte, handle func([]byte, V) bool) {
+	if a := t.arena; a != nil {
+		a.walkMatch(a.root, key, handle)
+	} else if t.size > 0 {
+		walkMatch(&t.root, key, handle)
 	}
-	return handle(netKeyToIPNet(p.external.key), p.external.value)
 }
 
 // WalkMatch interates routes that match a given route.
+// If `r` is not IPv4/IPv6 network, returns an error.
 // handle is called with arguments route and value (if handle returns `false`, the iteration is aborted)
-func (n *Net) WalkMatch(r *net.IPNet, handle func(*net.IPNet, interface{}) bool) {
-	if n.trie.size > 0 {
-		walkMatch(&n.trie.root, netIPNetToKey(r.IP, r.Mask), handle)
+func (n *NetOf[V]) WalkMatch(r *net.IPNet, handle func(*net.IPNet, V) bool) error {
+	key, err := n.ipNetKey(r, false)
+	if err != nil {
+		return err
 	}
+	n.table(key).walkMatch(key, netIPNetHandle(handle))
+	return nil
 }
 
 // Deletes all routes.
-func (n *Net) Clear() {
-	n.trie.Clear()
+func (n *NetOf[V]) Clear() {
+	for _, t := range n.tries {
+		t.Clear()
+	}
 }
 
 // Returns number of routes.
-func (n *Net) Size() int {
-	return n.trie.Size()
+func (n *NetOf[V]) Size() int {
+	return n.tries[IPv4].Size() + n.tries[IPv6].Size()
+}
+
+// Returns number of routes of a given family.
+// If `f` is neither IPv4 nor IPv6, returns 0.
+func (n *NetOf[V]) FamilySize(f Family) int {
+	if !f.valid() {
+		return 0
+	}
+	return n.tries[f].Size()
+}
+
+// Returns a deep copy of the table.
+func (n *NetOf[V]) Clone() *NetOf[V] {
+	return &NetOf[V]{[2]*TrieOf[V]{n.tries[IPv4].Clone(), n.tries[IPv6].Clone()}, n.opts}
+}
+
+// Returns a point-in-time copy of the table in O(1).
+// The copy shares nodes with the original in the same way as Trie.Snapshot,
+// except that both can be changed, copying shared nodes before changing them.
+func (n *NetOf[V]) Snapshot() *NetOf[V] {
+	return &NetOf[V]{[2]*TrieOf[V]{n.tries[IPv4].snapshot(), n.tries[IPv6].snapshot()}, n.opts}
 }
 
 // Create IP routing table
-func NewNet() *Net {
-	return &Net{NewTrie()}
+func NewNet(opts ...Option) *Net {
+	return NewNetOf[interface{}](opts...)
+}
+
+// Create IP routing table holding values of type V.
+func NewNetOf[V any](opts ...Option) *NetOf[V] {
+	n := &NetOf[V]{}
+	for _, opt := range opts {
+		opt(&n.opts)
+	}
+	n.tries = [2]*TrieOf[V]{NewTrieOf[V](opts...), NewTrieOf[V](opts...)}
+	return n
+}
+
+// parsing CIDR notation of a specific route, keeping host bits of the IP address.
+func netParseCIDR(s string) (*net.IPNet, error) {
+	ip, r, err := net.ParseCIDR(s)
+	if err != nil {
+		// a valid IP address with a bad prefix length, such as "10.0.0.0/33"
+		if i := strings.LastIndexByte(s, '/'); i >= 0 && net.ParseIP(s[:i]) != nil {
+			return nil, &NetError{Err: ErrInvalidMask, Addr: s, Reason: "invalid prefix length"}
+		}
+		return nil, &NetError{Err: ErrInvalidIP, Addr: s, Reason: "not CIDR notation"}
+	}
+	r.IP = ip
+	return r, nil
 }
 
 func netValidateIP(ip net.IP) (nIP net.IP, isV4 bool, err error) {
@@ -293,25 +593,70 @@ func netValidateIP(ip net.IP) (nIP net.IP, isV4 bool, err error) {
 	} else if ip.To16() != nil {
 		nIP = ip
 	} else {
-		err = &net.AddrError{Err: "Invalid IP address", Addr: ip.String()}
+		err = &NetError{Err: ErrInvalidIP, Addr: ip.String()}
 	}
 	return
 }
 
 func netValidateIPNet(r *net.IPNet) (nIP net.IP, isV4 bool, err error) {
 	if r == nil {
-		err = &net.AddrError{Err: "IP network is nil"}
+		err = &NetError{Err: ErrNilNetwork}
 		return
 	}
 	return netValidateIP(r.IP)
 }
 
-func netIPNetToKey(ip net.IP, mask net.IPMask) []byte {
+func netIPNetToKey(ip net.IP, ones int) []byte {
 	// +--------------+------+
 	// | ip address.. | mask |
 	// +--------------+------+
-	ones, _ := mask.Size()
-	return append(ip, byte(ones))
+	// never appending to `ip`, which may share the backing array with the caller
+	k := make([]byte, len(ip)+1)
+	copy(k, ip)
+	k[len(ip)] = byte(ones)
+	return k
+}
+
+// returning the number of leading ones of a non-canonical mask.
+func netMaskLeadingOnes(mask net.IPMask) (ones int) {
+	for _, b := range mask {
+		if b != 0xff {
+			return ones + bits.LeadingZeros8(^b)
+		}
+		ones += 8
+	}
+	return
+}
+
+// clearing bits of the IP address of `key` beyond the mask, returning whether any bit is set.
+func netKeyClearHostBits(key []byte) (set bool) {
+	iplen := len(key) - 1
+	ones := int(key[iplen])
+	for i := ones >> 3; i < iplen; i++ {
+		var mask byte
+		if i == ones>>3 {
+			mask = 0xff << (8 - uint(ones&0x07))
+		}
+		if key[i]&^mask != 0 {
+			key[i] &= mask
+			set = true
+		}
+	}
+	return
+}
+
+// converting a handle of routes to a handle of keys.
+func netIPNetHandle[V any](handle func(*net.IPNet, V) bool) func([]byte, V) bool {
+	return func(key []byte, value V) bool {
+		return handle(netKeyToIPNet(key), value)
+	}
+}
+
+func netKeyFamily(k []byte) int {
+	if len(k) == net.IPv4len+1 {
+		return int(IPv4)
+	}
+	return int(IPv6)
 }
 
 func netKeyToIPNet(k []byte) *net.IPNet {
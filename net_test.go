@@ -1,7 +1,9 @@
 package critbitgo_test
 
 import (
+	"errors"
 	"net"
+	"net/netip"
 	"reflect"
 	"testing"
 
@@ -154,6 +156,68 @@ func TestNetWalk(t *testing.T) {
 	}
 }
 
+func TestNetWalkFrom(t *testing.T) {
+	trie := buildTestNet(t)
+
+	var ret []string
+	f := func(n *net.IPNet, _ interface{}) bool {
+		ret = append(ret, n.String())
+		return true
+	}
+
+	ret = []string{}
+	_, s, _ := net.ParseCIDR("192.168.1.32/27")
+	trie.WalkFrom(s, f)
+	exp := []string{"192.168.1.32/27", "192.168.1.32/30", "192.168.2.1/32", "192.168.2.2/32"}
+	if !reflect.DeepEqual(ret, exp) {
+		t.Errorf("WalkFrom() - failed %s", ret)
+	}
+
+	ret = []string{}
+	trie.WalkAfter(s, f)
+	if !reflect.DeepEqual(ret, exp[1:]) {
+		t.Errorf("WalkAfter() - failed %s", ret)
+	}
+
+	ret = []string{}
+	_, s, _ = net.ParseCIDR("192.168.1.64/26")
+	trie.WalkFrom(s, f)
+	if !reflect.DeepEqual(ret, exp[2:]) {
+		t.Errorf("WalkFrom() - not found start route %s", ret)
+	}
+
+	ret = []string{}
+	_, s, _ = net.ParseCIDR("10.0.0.0/0")
+	trie.WalkFrom(s, f)
+	if len(ret) != 11 {
+		t.Errorf("WalkFrom() - not found start route %s", ret)
+	}
+}
+
+func TestNetWalkReverse(t *testing.T) {
+	trie := buildTestNet(t)
+
+	var ret []string
+	f := func(n *net.IPNet, _ interface{}) bool {
+		ret = append(ret, n.String())
+		return true
+	}
+
+	ret = []string{}
+	trie.WalkReverse(nil, f)
+	if len(ret) != 11 || ret[0] != "192.168.2.2/32" || ret[10] != "10.0.0.0/8" {
+		t.Errorf("WalkReverse() - full walk %s", ret)
+	}
+
+	ret = []string{}
+	_, s, _ := net.ParseCIDR("192.168.1.0/26")
+	trie.WalkReverse(s, f)
+	exp := []string{"192.168.1.0/24", "192.168.0.0/16", "10.0.0.0/8"}
+	if !reflect.DeepEqual(ret, exp) {
+		t.Errorf("WalkReverse() - failed %s", ret)
+	}
+}
+
 func TestNetWalkPrefix(t *testing.T) {
 	trie := buildTestNet(t)
 
@@ -234,3 +298,515 @@ func TestNetWalkMatch(t *testing.T) {
 		t.Errorf("WalkMatch() - failed %s", ret)
 	}
 }
+
+func TestNetSnapshot(t *testing.T) {
+	trie := buildTestNet(t)
+	snapshot := trie.Snapshot()
+	clone := trie.Clone()
+
+	trie.DeleteCIDR("192.168.1.0/24")
+	trie.AddCIDR("172.16.0.0/12", "172.16.0.0/12")
+	for _, s := range []*critbitgo.Net{snapshot, clone} {
+		if s.Size() != 11 {
+			t.Errorf("Snapshot() - invalid size [%d]", s.Size())
+		}
+		checkMatch(t, s, "192.168.1.128/26", "192.168.1.0/24")
+		if r, _, _ := s.MatchCIDR("172.16.0.1/32"); r != nil {
+			t.Errorf("Snapshot() - phantom found [%s]", r)
+		}
+	}
+	checkMatch(t, trie, "192.168.1.128/26", "192.168.0.0/16")
+}
+
+func TestNewNetFromSorted(t *testing.T) {
+	cidrs := []string{
+		"10.0.0.0/8",
+		"192.168.0.0/16",
+		"192.168.1.0/24",
+		"192.168.1.0/28",
+		"192.168.1.0/32",
+		"192.168.1.1/32",
+		"192.168.1.2/32",
+		"192.168.1.32/27",
+		"192.168.1.32/30",
+		"192.168.2.1/32",
+		"192.168.2.2/32",
+	}
+	values := make([]interface{}, len(cidrs))
+	for i, cidr := range cidrs {
+		values[i] = cidr
+	}
+	trie, err := critbitgo.NewNetFromSorted(cidrs, values)
+	if err != nil {
+		t.Fatalf("NewNetFromSorted() - error occurred %s", err)
+	}
+	if trie.Size() != len(cidrs) {
+		t.Errorf("NewNetFromSorted() - invalid size [%d]", trie.Size())
+	}
+	checkMatch(t, trie, "192.168.1.3/32", "192.168.1.0/28")
+	checkMatch(t, trie, "192.168.1.35/32", "192.168.1.32/30")
+	checkMatch(t, trie, "192.168.3.1/32", "192.168.0.0/16")
+
+	tests := []struct {
+		cidrs []string
+		err   error
+	}{
+		{[]string{"192.168.0.0/16", "10.0.0.0/8"}, critbitgo.ErrUnsortedKeys},
+		{[]string{"10.0.0.0/16", "10.0.0.0/8"}, critbitgo.ErrUnsortedKeys},
+		{[]string{"10.0.0.0/8", "10.1.0.0/8"}, critbitgo.ErrDuplicateKey},
+		{[]string{"10.0.0.0/8", "10.0.0.0"}, nil},
+	}
+	for _, test := range tests {
+		trie, err := critbitgo.NewNetFromSorted(test.cidrs, nil)
+		if trie != nil || err == nil || (test.err != nil && err != test.err) {
+			t.Errorf("NewNetFromSorted() - %q: invalid result [%v]", test.cidrs, err)
+		}
+	}
+}
+
+func TestNetKeyOwnership(t *testing.T) {
+	trie := critbitgo.NewNet()
+	buf := make([]byte, 4, 16)
+	copy(buf, []byte{10, 0, 0, 0})
+	r := &net.IPNet{IP: net.IP(buf), Mask: net.CIDRMask(8, 32)}
+	if err := trie.Add(r, "10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+	if buf[:5][4] != 0 {
+		t.Error("Add() - changed the buffer of the caller")
+	}
+	buf[0] = 192
+	checkMatch(t, trie, "10.1.1.1/32", "10.0.0.0/8")
+}
+
+func TestNetStats(t *testing.T) {
+	s := buildTestNet(t).Stats()
+	if s.Externals != 11 || s.Internals != 10 || s.KeyBytes != 11*5 {
+		t.Errorf("Stats() - invalid stats %+v", s)
+	}
+}
+
+func TestNetOf(t *testing.T) {
+	trie := critbitgo.NewNetOf[int]()
+	cidrs := []string{"10.0.0.0/8", "192.168.0.0/16", "192.168.1.0/24"}
+	for i, cidr := range cidrs {
+		if err := trie.AddCIDR(cidr, i); err != nil {
+			t.Errorf("AddCIDR() - %s: error occurred %s", cidr, err)
+		}
+	}
+
+	if r, v, err := trie.MatchIP(net.IPv4(192, 168, 1, 1)); r == nil || r.String() != cidrs[2] || v != 2 || err != nil {
+		t.Errorf("MatchIP() - failed: %v, %v, %v", r, v, err)
+	}
+	if r, v, err := trie.MatchIP(net.IPv4(172, 16, 0, 1)); r != nil || v != 0 || err != nil {
+		t.Errorf("MatchIP() - phantom: %v, %v, %v", r, v, err)
+	}
+	if v, ok, err := trie.GetCIDR(cidrs[1]); v != 1 || !ok || err != nil {
+		t.Errorf("GetCIDR() - failed: %v, %v, %v", v, ok, err)
+	}
+}
+
+func TestNetPrefix(t *testing.T) {
+	trie := buildTestNet(t)
+	if err := trie.AddPrefix(netip.MustParsePrefix("2001:db8::/32"), "2001:db8::/32"); err != nil {
+		t.Fatal(err)
+	}
+	if err := trie.AddPrefix(netip.Prefix{}, nil); err == nil {
+		t.Error("AddPrefix() - not error")
+	}
+
+	if v, ok, err := trie.GetPrefix(netip.MustParsePrefix("192.168.1.0/24")); v != "192.168.1.0/24" || !ok || err != nil {
+		t.Errorf("GetPrefix() - failed: %v, %v, %v", v, ok, err)
+	}
+	// an IPv4-mapped IPv6 prefix is the same as the IPv4 prefix, as with net.IP
+	if v, ok, err := trie.GetPrefix(netip.MustParsePrefix("::ffff:192.168.1.0/120")); v != "192.168.1.0/24" || !ok || err != nil {
+		t.Errorf("GetPrefix() - failed: %v, %v, %v", v, ok, err)
+	}
+	if v, ok, err := trie.GetPrefix(netip.MustParsePrefix("192.168.3.0/24")); v != nil || ok || err != nil {
+		t.Errorf("GetPrefix() - phantom: %v, %v, %v", v, ok, err)
+	}
+
+	for _, c := range []struct{ addr, exp string }{
+		{"10.1.1.1", "10.0.0.0/8"},
+		{"192.168.1.35", "192.168.1.32/30"},
+		{"192.168.2.3", "192.168.0.0/16"},
+		{"::ffff:192.168.1.1", "192.168.1.1/32"},
+		{"2001:db8::1", "2001:db8::/32"},
+		{"172.16.0.1", ""},
+		{"::a00:1", ""},
+	} {
+		r, v, ok := trie.MatchAddr(netip.MustParseAddr(c.addr))
+		if c.exp == "" {
+			if r.IsValid() || v != nil || ok {
+				t.Errorf("MatchAddr() - %s: phantom: %v, %v, %v", c.addr, r, v, ok)
+			}
+		} else if r.String() != c.exp || v != c.exp || !ok {
+			t.Errorf("MatchAddr() - %s: failed: %v, %v, %v", c.addr, r, v, ok)
+		}
+	}
+	if r, _, ok, err := trie.MatchPrefix(netip.MustParsePrefix("192.168.1.0/30")); r.String() != "192.168.1.0/28" || !ok || err != nil {
+		t.Errorf("MatchPrefix() - failed: %v, %v, %v", r, ok, err)
+	}
+	if _, _, ok := trie.MatchAddr(netip.Addr{}); ok {
+		t.Error("MatchAddr() - matched an invalid address")
+	}
+
+	if v, ok, err := trie.DeletePrefix(netip.MustParsePrefix("2001:db8::/32")); v != "2001:db8::/32" || !ok || err != nil {
+		t.Errorf("DeletePrefix() - failed: %v, %v, %v", v, ok, err)
+	}
+	if v, ok, err := trie.DeletePrefix(netip.MustParsePrefix("2001:db8::/32")); v != nil || ok || err != nil {
+		t.Errorf("DeletePrefix() - phantom: %v, %v, %v", v, ok, err)
+	}
+	if trie.Size() != 11 {
+		t.Errorf("Size() - invalid size %d", trie.Size())
+	}
+}
+
+func TestNetMatchAddrAllocs(t *testing.T) {
+	trie := buildTestNet(t)
+	addr := netip.MustParseAddr("192.168.1.35")
+	p := netip.MustParsePrefix("192.168.1.0/24")
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, _, ok := trie.MatchAddr(addr); !ok {
+			t.Fatal("MatchAddr() - not found")
+		}
+		if _, ok, _ := trie.GetPrefix(p); !ok {
+			t.Fatal("GetPrefix() - not found")
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("MatchAddr() - allocated %v times", allocs)
+	}
+}
+
+func TestNetWalkPrefixes(t *testing.T) {
+	trie := buildTestNet(t)
+	trie.AddPrefix(netip.MustParsePrefix("c0a8::/16"), "c0a8::/16")
+
+	collect := func(walk func(func(netip.Prefix, interface{}) bool)) (ret []string) {
+		walk(func(p netip.Prefix, v interface{}) bool {
+			if p.String() != v {
+				t.Errorf("invalid value %v of %s", v, p)
+			}
+			ret = append(ret, p.String())
+			return true
+		})
+		return
+	}
+
+	for _, c := range []struct {
+		prefix string
+		exp    []string
+	}{
+		{"192.168.1.0/24", []string{
+			"192.168.1.0/24", "192.168.1.0/28", "192.168.1.0/32", "192.168.1.1/32",
+			"192.168.1.2/32", "192.168.1.32/27", "192.168.1.32/30",
+		}},
+		{"192.168.1.32/27", []string{"192.168.1.32/27", "192.168.1.32/30"}},
+		{"192.168.1.33/28", []string{"192.168.1.32/30"}},
+		{"192.168.2.0/23", []string{"192.168.2.1/32", "192.168.2.2/32"}},
+		{"192.0.0.0/8", []string{
+			"192.168.0.0/16", "192.168.1.0/24", "192.168.1.0/28", "192.168.1.0/32",
+			"192.168.1.1/32", "192.168.1.2/32", "192.168.1.32/27", "192.168.1.32/30",
+			"192.168.2.1/32", "192.168.2.2/32",
+		}},
+		{"c000::/8", []string{"c0a8::/16"}},
+		{"11.0.0.0/8", nil},
+	} {
+		p := netip.MustParsePrefix(c.prefix)
+		if ret := collect(func(h func(netip.Prefix, interface{}) bool) { trie.WalkWithinPrefix(p, h) }); !reflect.DeepEqual(ret, c.exp) {
+			t.Errorf("WalkWithinPrefix() - %s: failed %s", c.prefix, ret)
+		}
+	}
+
+	exp := []string{"192.168.0.0/16", "192.168.1.0/24", "192.168.1.0/28", "192.168.1.1/32"}
+	if ret := collect(func(h func(netip.Prefix, interface{}) bool) {
+		trie.WalkMatchPrefix(netip.MustParsePrefix("192.168.1.1/32"), h)
+	}); !reflect.DeepEqual(ret, exp) {
+		t.Errorf("WalkMatchPrefix() - failed %s", ret)
+	}
+
+	exp = []string{"192.168.2.1/32", "192.168.2.2/32", "c0a8::/16"}
+	if ret := collect(func(h func(netip.Prefix, interface{}) bool) {
+		trie.WalkFromPrefix(netip.MustParsePrefix("192.168.2.0/24"), h)
+	}); !reflect.DeepEqual(ret, exp) {
+		t.Errorf("WalkFromPrefix() - failed %s", ret)
+	}
+
+	var n int
+	for p, v := range trie.Prefixes() {
+		if p.String() != v {
+			t.Errorf("Prefixes() - invalid value %v of %s", v, p)
+		}
+		n++
+	}
+	if n != trie.Size() {
+		t.Errorf("Prefixes() - invalid count %d", n)
+	}
+	exp = []string{"192.168.1.32/27", "192.168.1.32/30"}
+	if ret := collect(func(h func(netip.Prefix, interface{}) bool) {
+		for p, v := range trie.PrefixesWithin(netip.MustParsePrefix("192.168.1.32/27")) {
+			if !h(p, v) {
+				return
+			}
+		}
+	}); !reflect.DeepEqual(ret, exp) {
+		t.Errorf("PrefixesWithin() - failed %s", ret)
+	}
+}
+
+func TestNetFamilies(t *testing.T) {
+	trie := critbitgo.NewNet()
+	cidrs := []string{"::/0", "0a00::/8", "2001:db8::/32", "0.0.0.0/0", "10.0.0.0/8", "192.168.0.0/16"}
+	for _, cidr := range cidrs {
+		if err := trie.AddCIDR(cidr, cidr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if trie.Size() != 6 || trie.FamilySize(critbitgo.IPv4) != 3 || trie.FamilySize(critbitgo.IPv6) != 3 {
+		t.Errorf("FamilySize() - invalid sizes %d, %d", trie.FamilySize(critbitgo.IPv4), trie.FamilySize(critbitgo.IPv6))
+	}
+
+	var ret []string
+	f := func(n *net.IPNet, _ interface{}) bool {
+		ret = append(ret, n.String())
+		return true
+	}
+	trie.Walk(nil, f)
+	exp := []string{"0.0.0.0/0", "10.0.0.0/8", "192.168.0.0/16", "::/0", "a00::/8", "2001:db8::/32"}
+	if !reflect.DeepEqual(ret, exp) {
+		t.Errorf("Walk() - failed %s", ret)
+	}
+	ret = nil
+	if err := trie.WalkFamily(critbitgo.IPv6, f); err != nil || !reflect.DeepEqual(ret, exp[3:]) {
+		t.Errorf("WalkFamily() - failed %s, %v", ret, err)
+	}
+	if err := trie.WalkFamily(critbitgo.Family(2), f); !errors.Is(err, critbitgo.ErrInvalidFamily) {
+		t.Errorf("WalkFamily() - invalid family: invalid error %v", err)
+	}
+	if s := trie.FamilySize(critbitgo.Family(-1)); s != 0 {
+		t.Errorf("FamilySize() - invalid family: invalid size %d", s)
+	}
+	ret = nil
+	_, s, _ := net.ParseCIDR("10.0.0.0/8")
+	trie.WalkAfter(s, f)
+	if !reflect.DeepEqual(ret, exp[2:]) {
+		t.Errorf("WalkAfter() - failed %s", ret)
+	}
+	ret = nil
+	_, s, _ = net.ParseCIDR("::/1")
+	trie.WalkReverse(s, f)
+	if exp := []string{"::/0", "192.168.0.0/16", "10.0.0.0/8", "0.0.0.0/0"}; !reflect.DeepEqual(ret, exp) {
+		t.Errorf("WalkReverse() - failed %s", ret)
+	}
+
+	// routes of the other family never match
+	checkMatch(t, trie, "10.1.1.1/32", "10.0.0.0/8")
+	checkMatch(t, trie, "a01:101::/32", "a00::/8")
+	ret = nil
+	_, s, _ = net.ParseCIDR("a00::/16")
+	trie.WalkMatch(s, f)
+	if exp := []string{"::/0", "a00::/8"}; !reflect.DeepEqual(ret, exp) {
+		t.Errorf("WalkMatch() - failed %s", ret)
+	}
+
+	if _, err := critbitgo.NewNetFromSorted(exp, nil); err != nil {
+		t.Errorf("NewNetFromSorted() - error occurred %s", err)
+	}
+	if _, err := critbitgo.NewNetFromSorted([]string{"::/0", "10.0.0.0/8"}, nil); err != critbitgo.ErrUnsortedKeys {
+		t.Errorf("NewNetFromSorted() - IPv6 before IPv4: %v", err)
+	}
+}
+
+func TestNetMappedAddrs(t *testing.T) {
+	mapped := "::ffff:10.0.0.0/104"
+	for _, c := range []struct {
+		policy critbitgo.MappedPolicy
+		v4, v6 int
+		match  string
+	}{
+		{critbitgo.UnmapMapped, 2, 1, "10.0.0.0/8"},
+		{critbitgo.KeepMapped, 1, 2, mapped},
+		{critbitgo.RejectMapped, 1, 1, ""},
+	} {
+		// the same for net.IPNet and netip.Prefix
+		for i := 0; i < 2; i++ {
+			trie := critbitgo.NewNetOf[string](critbitgo.WithMappedAddrs(c.policy))
+			for _, cidr := range []string{"10.0.0.0/9", "2001:db8::/32", mapped} {
+				var err error
+				if i == 0 {
+					_, r, _ := net.ParseCIDR(cidr)
+					err = trie.Add(r, cidr)
+				} else {
+					err = trie.AddPrefix(netip.MustParsePrefix(cidr), cidr)
+				}
+				if rejected := c.policy == critbitgo.RejectMapped && cidr == mapped; (err != nil) != rejected {
+					t.Errorf("Add() - %d, %s: unexpected error %v", c.policy, cidr, err)
+				}
+			}
+			if trie.FamilySize(critbitgo.IPv4) != c.v4 || trie.FamilySize(critbitgo.IPv6) != c.v6 {
+				t.Errorf("FamilySize() - %d: invalid sizes %d, %d",
+					c.policy, trie.FamilySize(critbitgo.IPv4), trie.FamilySize(critbitgo.IPv6))
+			}
+
+			if err := trie.AddCIDR("10.0.0.0/8", "10.0.0.0/8"); err != nil {
+				t.Fatal(err)
+			}
+			if _, v, ok := trie.MatchAddr(netip.MustParseAddr("::ffff:10.200.0.1")); v != c.match || ok != (c.match != "") {
+				t.Errorf("MatchAddr() - %d: failed %s, %v", c.policy, v, ok)
+			}
+			// net.IP without a mask is always IPv4
+			if _, v, _ := trie.MatchIP(net.ParseIP("::ffff:10.200.0.1")); v != "10.0.0.0/8" {
+				t.Errorf("MatchIP() - %d: failed %s", c.policy, v)
+			}
+		}
+	}
+}
+
+func TestNetHostBits(t *testing.T) {
+	host := &net.IPNet{IP: net.IPv4(10, 0, 0, 5), Mask: net.CIDRMask(8, 32)}
+	noncanonical := &net.IPNet{IP: net.IPv4(172, 16, 0, 0), Mask: net.IPv4Mask(255, 240, 255, 0)}
+	v6mask := &net.IPNet{IP: net.ParseIP("2001:db8::"), Mask: net.CIDRMask(16, 32)}
+
+	// lenient by default
+	trie := critbitgo.NewNet()
+	for _, r := range []*net.IPNet{host, noncanonical, v6mask} {
+		if err := trie.Add(r, r.String()); err != nil {
+			t.Errorf("Add() - %s: error occurred %s", r, err)
+		}
+	}
+	if err := trie.Add(&net.IPNet{IP: net.IPv4(10, 0, 0, 0)}, nil); err == nil {
+		t.Error("Add() - not error without a mask")
+	}
+	if err := trie.AddPrefix(netip.MustParsePrefix("192.168.1.1/24"), "192.168.1.1/24"); err != nil {
+		t.Errorf("AddPrefix() - error occurred %s", err)
+	}
+	var ret []string
+	trie.Walk(nil, func(r *net.IPNet, _ interface{}) bool {
+		ret = append(ret, r.String())
+		return true
+	})
+	if exp := []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.1.0/24", "2001::/16"}; !reflect.DeepEqual(ret, exp) {
+		t.Errorf("Add() - not masked %s", ret)
+	}
+	if v, ok, err := trie.Get(host); v != host.String() || !ok || err != nil {
+		t.Errorf("Get() - failed: %v, %v, %v", v, ok, err)
+	}
+	if v, ok, err := trie.GetPrefix(netip.MustParsePrefix("192.168.1.2/24")); v != "192.168.1.1/24" || !ok || err != nil {
+		t.Errorf("GetPrefix() - failed: %v, %v, %v", v, ok, err)
+	}
+	if v, ok, err := trie.DeleteCIDR("10.1.2.3/8"); v != host.String() || !ok || err != nil {
+		t.Errorf("DeleteCIDR() - failed: %v, %v, %v", v, ok, err)
+	}
+
+	// strict
+	trie = critbitgo.NewNet(critbitgo.WithHostBits(critbitgo.RejectHostBits))
+	for _, r := range []*net.IPNet{host, noncanonical, v6mask} {
+		if err := trie.Add(r, r.String()); err == nil {
+			t.Errorf("Add() - %s: not error", r)
+		}
+	}
+	if err := trie.AddPrefix(netip.MustParsePrefix("192.168.1.1/24"), nil); err == nil {
+		t.Error("AddPrefix() - not error")
+	}
+	if _, _, err := trie.GetCIDR("10.0.0.5/8"); err == nil {
+		t.Error("GetCIDR() - not error")
+	}
+	if err := trie.AddCIDR("10.0.0.0/8", "10.0.0.0/8"); err != nil {
+		t.Errorf("AddCIDR() - error occurred %s", err)
+	}
+	if trie.Size() != 1 {
+		t.Errorf("Size() - invalid size %d", trie.Size())
+	}
+	// host bits of routes to match are not rejected
+	checkMatch(t, trie, "10.1.1.1/16", "10.0.0.0/8")
+	if _, v, ok, err := trie.MatchPrefix(netip.MustParsePrefix("10.1.1.1/16")); v != "10.0.0.0/8" || !ok || err != nil {
+		t.Errorf("MatchPrefix() - failed: %v, %v, %v", v, ok, err)
+	}
+}
+
+func TestNetErrors(t *testing.T) {
+	trie := buildTestNet(t)
+	_, known, _ := net.ParseCIDR("192.168.1.0/24")
+	_, unknown, _ := net.ParseCIDR("192.168.3.0/24")
+	invalid := &net.IPNet{IP: net.IP{1, 2, 3}, Mask: net.CIDRMask(8, 32)}
+	nomask := &net.IPNet{IP: net.IPv4(10, 0, 0, 0)}
+	f := func(*net.IPNet, interface{}) bool { return true }
+
+	for _, c := range []struct {
+		name string
+		err  error
+		exp  error
+	}{
+		{"AddCIDR", trie.AddCIDR("", nil), critbitgo.ErrInvalidIP},
+		{"AddCIDR", trie.AddCIDR("10.0.0.0/8", "10.0.0.0/8"), nil},
+		{"Add", trie.Add(nil, nil), critbitgo.ErrNilNetwork},
+		{"Add", trie.Add(invalid, nil), critbitgo.ErrInvalidIP},
+		{"Add", trie.Add(nomask, nil), critbitgo.ErrInvalidMask},
+		{"AddPrefix", trie.AddPrefix(netip.Prefix{}, nil), critbitgo.ErrInvalidIP},
+		{"Walk", trie.Walk(known, f), nil},
+		{"Walk", trie.Walk(unknown, f), critbitgo.ErrNotFound},
+		{"Walk", trie.Walk(invalid, f), critbitgo.ErrInvalidIP},
+		{"WalkFrom", trie.WalkFrom(nomask, f), critbitgo.ErrInvalidMask},
+		{"WalkAfter", trie.WalkAfter(invalid, f), critbitgo.ErrInvalidIP},
+		{"WalkReverse", trie.WalkReverse(invalid, f), critbitgo.ErrInvalidIP},
+		{"WalkPrefix", trie.WalkPrefix(invalid, f), critbitgo.ErrInvalidIP},
+		{"WalkMatch", trie.WalkMatch(nil, f), critbitgo.ErrNilNetwork},
+		{"WalkMatch", trie.WalkMatch(invalid, f), critbitgo.ErrInvalidIP},
+		{"WalkWithinPrefix", trie.WalkWithinPrefix(netip.Prefix{}, nil), critbitgo.ErrInvalidIP},
+		{"WalkMatchPrefix", trie.WalkMatchPrefix(netip.Prefix{}, nil), critbitgo.ErrInvalidIP},
+	} {
+		if !errors.Is(c.err, c.exp) || (c.exp == nil) != (c.err == nil) {
+			t.Errorf("%s() - unexpected error %v", c.name, c.err)
+		}
+	}
+
+	_, _, err := trie.Match(nil)
+	if !errors.Is(err, critbitgo.ErrNilNetwork) {
+		t.Errorf("Match() - unexpected error %v", err)
+	}
+	_, _, err = trie.MatchIP(net.IP{1, 2, 3})
+	var e *critbitgo.NetError
+	if !errors.As(err, &e) || e.Err != critbitgo.ErrInvalidIP || e.Addr != "?010203" {
+		t.Errorf("MatchIP() - unexpected error %v", err)
+	}
+	_, _, err = trie.DeleteCIDR("10.0.0.0/33")
+	if !errors.As(err, &e) || e.Err != critbitgo.ErrInvalidMask || e.Error() != "critbitgo: invalid IP mask (invalid prefix length): 10.0.0.0/33" {
+		t.Errorf("DeleteCIDR() - unexpected error %v", err)
+	}
+	for s, exp := range map[string]error{"2001:db8::/129": critbitgo.ErrInvalidMask, "10.0.0.0/x": critbitgo.ErrInvalidMask, "10.0.0/8": critbitgo.ErrInvalidIP, "10.0.0.0": critbitgo.ErrInvalidIP} {
+		if _, _, err := trie.GetCIDR(s); !errors.Is(err, exp) {
+			t.Errorf("GetCIDR() - %s: unexpected error %v", s, err)
+		}
+	}
+	if _, ok, err := trie.GetPrefix(netip.Prefix{}); ok || !errors.Is(err, critbitgo.ErrInvalidIP) {
+		t.Errorf("GetPrefix() - unexpected error %v", err)
+	}
+	if _, ok, err := trie.DeletePrefix(netip.Prefix{}); ok || !errors.Is(err, critbitgo.ErrInvalidIP) {
+		t.Errorf("DeletePrefix() - unexpected error %v", err)
+	}
+	if _, _, ok, err := trie.MatchPrefix(netip.Prefix{}); ok || !errors.Is(err, critbitgo.ErrInvalidIP) {
+		t.Errorf("MatchPrefix() - unexpected error %v", err)
+	}
+	err = trie.WalkFamily(critbitgo.Family(2), f)
+	if !errors.As(err, &e) || e.Err != critbitgo.ErrInvalidFamily || e.Error() != "critbitgo: invalid address family (family 2)" {
+		t.Errorf("WalkFamily() - unexpected error %v", err)
+	}
+
+	strict := critbitgo.NewNet(critbitgo.WithHostBits(critbitgo.RejectHostBits), critbitgo.WithMappedAddrs(critbitgo.RejectMapped))
+	if err := strict.AddCIDR("10.0.0.1/8", nil); !errors.Is(err, critbitgo.ErrInvalidMask) {
+		t.Errorf("AddCIDR() - unexpected error %v", err)
+	}
+	if err := strict.AddCIDR("::ffff:10.0.0.0/104", nil); !errors.Is(err, critbitgo.ErrInvalidIP) {
+		t.Errorf("AddCIDR() - unexpected error %v", err)
+	}
+	if _, _, err := strict.DeletePrefix(netip.MustParsePrefix("10.0.0.1/8")); !errors.Is(err, critbitgo.ErrInvalidMask) {
+		t.Errorf("DeletePrefix() - unexpected error %v", err)
+	}
+	if _, _, _, err := strict.MatchPrefix(netip.MustParsePrefix("::ffff:10.0.0.0/104")); !errors.Is(err, critbitgo.ErrInvalidIP) {
+		t.Errorf("MatchPrefix() - unexpected error %v", err)
+	}
+	if strict.Size() != 0 {
+		t.Errorf("Size() - invalid size %d", strict.Size())
+	}
+}
@@ -0,0 +1,183 @@
+package critbitgo
+
+import (
+	"net"
+	"net/netip"
+)
+
+// Add a route.
+// If `p` is not a valid prefix, returns an error.
+func (n *NetOf[V]) AddPrefix(p netip.Prefix, value V) error {
+	key, err := n.prefixKey(nil, p, true)
+	if err != nil {
+		return err
+	}
+	n.table(key).Set(key, value)
+	return nil
+}
+
+// Delete a specific route.
+// If `p` is not a valid prefix or a route is not found, `ok` is false.
+func (n *NetOf[V]) DeletePrefix(p netip.Prefix) (value V, ok bool, err error) {
+	var buf [17]byte
+	var key []byte
+	if key, err = n.prefixKey(buf[:0], p, true); err == nil {
+		value, ok = n.table(key).Delete(key)
+	}
+	return
+}
+
+// Get a specific route.
+// If `p` is not a valid prefix or a route is not found, `ok` is false.
+func (n *NetOf[V]) GetPrefix(p netip.Prefix) (value V, ok bool, err error) {
+	var buf [17]byte
+	var key []byte
+	if key, err = n.prefixKey(buf[:0], p, true); err == nil {
+		value, ok = n.table(key).Get(key)
+	}
+	return
+}
+
+// Return a specific route by using the longest prefix matching.
+// If `p` is not a valid prefix, returns an error.
+// If a route is not found, `ok` is false.
+// It does not allocate memory, unless it returns an error.
+func (n *NetOf[V]) MatchPrefix(p netip.Prefix) (route netip.Prefix, value V, ok bool, err error) {
+	var buf [17]byte
+	var key []byte
+	if key, err = n.prefixKey(buf[:0], p, false); err == nil {
+		route, value, ok = n.matchKey(key)
+	}
+	return
+}
+
+// Return a specific route by using the longest prefix matching.
+// If `addr` is not a valid address or a route is not found, `ok` is false.
+// It does not allocate memory.
+func (n *NetOf[V]) MatchAddr(addr netip.Addr) (route netip.Prefix, value V, ok bool) {
+	var buf [17]byte
+	if key, err := n.prefixKey(buf[:0], netip.PrefixFrom(addr, addr.BitLen()), false); err == nil {
+		route, value, ok = n.matchKey(key)
+	}
+	return
+}
+
+// returning the route matching a key by using the longest prefix matching.
+func (n *NetOf[V]) matchKey(key []byte) (route netip.Prefix, value V, ok bool) {
+	var k []byte
+	if k, value = n.match(key); k != nil {
+		route, ok = netKeyToPrefix(k), true
+	}
+	return
+}
+
+// WalkFromPrefix iterates routes from the first route that is equal to or greater than a given prefix.
+// If `p` is the zero Prefix, the iteration starts from the first route.
+// If `p` is rejected by WithMappedAddrs, returns an error.
+// handle is called with arguments route and value (if handle returns `false`, the iteration is aborted)
+func (n *NetOf[V]) WalkFromPrefix(p netip.Prefix, handle func(netip.Prefix, V) bool) error {
+	var key []byte
+	if p != (netip.Prefix{}) {
+		var buf [17]byte
+		var err error
+		if key, err = n.prefixKey(buf[:0], p, false); err != nil {
+			return err
+		}
+	}
+	n.walk(key, (*TrieOf[V]).WalkFrom, func(key []byte, value V) bool {
+		return handle(netKeyToPrefix(key), value)
+	})
+	return nil
+}
+
+// WalkWithinPrefix iterates routes that are within a given prefix, including the prefix itself.
+// If `p` is not a valid prefix, returns an error.
+// handle is called with arguments route and value (if handle returns `false`, the iteration is aborted)
+func (n *NetOf[V]) WalkWithinPrefix(p netip.Prefix, handle func(netip.Prefix, V) bool) error {
+	var buf [17]byte
+	start, err := n.prefixKey(buf[:0], p.Masked(), false)
+	if err != nil {
+		return err
+	}
+	bits := int(start[len(start)-1])
+	// keys within the prefix are contiguous
+	n.table(start).WalkFrom(start, func(key []byte, value V) bool {
+		if !netKeyHasPrefix(key, start, bits) {
+			return false
+		}
+		if int(key[len(key)-1]) < bits {
+			return true
+		}
+		return handle(netKeyToPrefix(key), value)
+	})
+	return nil
+}
+
+// WalkMatchPrefix iterates routes that match a given prefix, from the shortest mask.
+// If `p` is not a valid prefix, returns an error.
+// handle is called with arguments route and value (if handle returns `false`, the iteration is aborted)
+func (n *NetOf[V]) WalkMatchPrefix(p netip.Prefix, handle func(netip.Prefix, V) bool) error {
+	var buf [17]byte
+	key, err := n.prefixKey(buf[:0], p, false)
+	if err != nil {
+		return err
+	}
+	n.table(key).walkMatch(key, func(k []byte, value V) bool {
+		return handle(netKeyToPrefix(k), value)
+	})
+	return nil
+}
+
+// appending the key of a prefix to `buf`, in the same layout as netIPNetToKey.
+// If `route` is true, `p` is a specific route and its host bits are handled according to WithHostBits.
+// If `p` is not valid, returns an error.
+func (n *NetOf[V]) prefixKey(buf []byte, p netip.Prefix, route bool) ([]byte, error) {
+	if !p.IsValid() {
+		return nil, &NetError{Err: ErrInvalidIP, Addr: p.String(), Reason: "invalid prefix"}
+	}
+	addr, bits := p.Addr(), p.Bits()
+	if addr.Is4In6() {
+		switch {
+		case n.opts.mapped == RejectMapped:
+			return nil, &NetError{Err: ErrInvalidIP, Addr: p.String(), Reason: "IPv4-mapped IPv6 address"}
+		case n.opts.mapped == UnmapMapped && bits >= 96:
+			addr, bits = addr.Unmap(), bits-96
+		}
+	}
+	if addr.Is4() {
+		a := addr.As4()
+		buf = append(buf, a[:]...)
+	} else {
+		a := addr.As16()
+		buf = append(buf, a[:]...)
+	}
+	key := append(buf, byte(bits))
+	if route && !n.clearHostBits(key) {
+		return nil, &NetError{Err: ErrInvalidMask, Addr: p.String(), Reason: "host bits set"}
+	}
+	return key, nil
+}
+
+func netKeyToPrefix(k []byte) netip.Prefix {
+	iplen := len(k) - 1
+	var addr netip.Addr
+	if iplen == net.IPv4len {
+		addr = netip.AddrFrom4([4]byte(k[:iplen]))
+	} else {
+		addr = netip.AddrFrom16([16]byte(k[:iplen]))
+	}
+	return netip.PrefixFrom(addr, int(k[iplen]))
+}
+
+// whether the first `bits` bits of `key` are equal to those of `prefix`.
+func netKeyHasPrefix(key, prefix []byte, bits int) bool {
+	div, mod := bits>>3, uint(bits&0x07)
+	if len(key) < div || string(key[:div]) != string(prefix[:div]) {
+		return false
+	}
+	if mod > 0 {
+		shift := 8 - mod
+		return len(key) > div && key[div]>>shift == prefix[div]>>shift
+	}
+	return true
+}
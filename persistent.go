@@ -0,0 +1,270 @@
+package critbitgo
+
+import (
+	"io"
+)
+
+// Immutable crit-bit tree holding values of type V.
+// Insert, Set and Delete return a new version of the tree which shares unchanged nodes
+// with the previous one, so that any version can be read while others are created.
+// A version is never modified, and is safe for concurrent reads.
+// A Snapshot of a tree with WithArena shares its arena, and Insert, Set and Delete on it copy
+// the tree out of the arena in O(n) first, so that later versions are not in an arena.
+type PersistentTrieOf[V any] struct {
+	trie TrieOf[V]
+}
+
+// Immutable crit-bit tree holding values of any type.
+type PersistentTrie = PersistentTrieOf[interface{}]
+
+// insert into the tree (replaceable), returning a new version.
+func (t *PersistentTrieOf[V]) insert(key []byte, value V, replace bool) (*PersistentTrieOf[V], bool) {
+	if t.trie.arena != nil {
+		if !replace && t.trie.Contains(key) {
+			return t, false
+		}
+		return t.withoutArena().insert(key, value, replace)
+	}
+
+	// an empty tree
+	if t.trie.size == 0 {
+		root := node[V]{external: &external[V]{key: t.trie.opts.storedKey(key), value: value}}
+		return t.version(root, 1), true
+	}
+
+	crit := t.trie.critNode(key)
+
+	// already exists in the tree
+	if crit == nil {
+		if !replace {
+			return t, false
+		}
+		root := copyPath(t.trie.root, key, nil, t.trie.opts.counted, 0, func(n node[V]) node[V] {
+			return node[V]{external: &external[V]{key: n.external.key, value: value}}
+		})
+		return t.version(root, t.trie.size), true
+	}
+
+	counted := t.trie.opts.counted
+	root := copyPath(t.trie.root, key, crit, counted, 1, func(n node[V]) node[V] {
+		// allocate new node
+		newNode := newInternal(internal[V]{critBit: *crit}, counted)
+		if counted {
+			newNode.counted().count = n.count() + 1
+		}
+		direction := newNode.direction(key)
+		newNode.child[direction].external = &external[V]{key: t.trie.opts.storedKey(key), value: value}
+		newNode.child[1-direction] = n
+		return node[V]{internal: newNode}
+	})
+	return t.version(root, t.trie.size+1), true
+}
+
+// copying nodes on the path to `key` above `crit`, then replacing the rest by `f`.
+// If `counted`, counts of the copies are increased by `delta`.
+func copyPath[V any](n node[V], key []byte, crit *critBit, counted bool, delta int, f func(node[V]) node[V]) node[V] {
+	if in := n.internal; in != nil && in.above(crit) {
+		c := copyInternal(in, counted)
+		if counted {
+			c.counted().count += delta
+		}
+		direction := in.direction(key)
+		c.child[direction] = copyPath(in.child[direction], key, crit, counted, delta, f)
+		return node[V]{internal: c}
+	}
+	return f(n)
+}
+
+// create a new version sharing options.
+// Versions are not in an arena, and copy keys instead with WithArena.
+func (t *PersistentTrieOf[V]) version(root node[V], size int) *PersistentTrieOf[V] {
+	opts := t.trie.opts
+	if opts.arena {
+		opts.arena = false
+		opts.copyKeys = true
+	}
+	return &PersistentTrieOf[V]{TrieOf[V]{root: root, size: size, opts: opts}}
+}
+
+// returning the same version with nodes copied out of the arena, referring to keys in the arena.
+func (t *PersistentTrieOf[V]) withoutArena() *PersistentTrieOf[V] {
+	a := t.trie.arena
+	return t.version(a.expand(a.root), t.trie.size)
+}
+
+// insert into the tree, returning a new version.
+// if `key` is alredy in Trie, return the receiver itself and false.
+func (t *PersistentTrieOf[V]) Insert(key []byte, value V) (*PersistentTrieOf[V], bool) {
+	return t.insert(key, value, false)
+}
+
+// set into the tree, returning a new version.
+func (t *PersistentTrieOf[V]) Set(key []byte, value V) *PersistentTrieOf[V] {
+	nt, _ := t.insert(key, value, true)
+	return nt
+}
+
+// deleting elements, returning a new version.
+// if `key` is in Trie, `ok` is true. Otherwise, return the receiver itself.
+func (t *PersistentTrieOf[V]) Delete(key []byte) (nt *PersistentTrieOf[V], value V, ok bool) {
+	if value, ok = t.trie.Get(key); !ok {
+		return t, value, false
+	}
+
+	// removing the last node
+	if t.trie.size == 1 {
+		return t.version(node[V]{}, 0), value, true
+	}
+	if t.trie.arena != nil {
+		t = t.withoutArena()
+	}
+
+	return t.version(deletePath(t.trie.root, key, t.trie.opts.counted), t.trie.size-1), value, true
+}
+
+// copying nodes on the path to `key`, then replacing the parent of `key` by its sibling.
+// If `counted`, counts of the copies are decreased.
+func deletePath[V any](n node[V], key []byte, counted bool) node[V] {
+	in := n.internal
+	direction := in.direction(key)
+	if in.child[direction].internal == nil {
+		return in.child[1-direction]
+	}
+	c := copyInternal(in, counted)
+	if counted {
+		c.counted().count -= 1
+	}
+	c.child[direction] = deletePath(in.child[direction], key, counted)
+	return node[V]{internal: c}
+}
+
+// return an empty version sharing options.
+func (t *PersistentTrieOf[V]) Clear() *PersistentTrieOf[V] {
+	return t.version(node[V]{}, 0)
+}
+
+// membership testing.
+func (t *PersistentTrieOf[V]) Contains(key []byte) bool {
+	return t.trie.Contains(key)
+}
+
+// get member.
+// if `key` is in Trie, `ok` is true.
+func (t *PersistentTrieOf[V]) Get(key []byte) (value V, ok bool) {
+	return t.trie.Get(key)
+}
+
+// return the number of key in a tree.
+func (t *PersistentTrieOf[V]) Size() int {
+	return t.trie.Size()
+}
+
+// fetching elements with a given prefix.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *PersistentTrieOf[V]) Allprefixed(prefix []byte, handle func(key []byte, value V) bool) bool {
+	return t.trie.Allprefixed(prefix, handle)
+}
+
+// fetching elements with a given prefix in reverse order.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *PersistentTrieOf[V]) AllprefixedReverse(prefix []byte, handle func(key []byte, value V) bool) bool {
+	return t.trie.AllprefixedReverse(prefix, handle)
+}
+
+// Search for the longest matching key from the beginning of the given key.
+// if `key` is in Trie, `ok` is true.
+func (t *PersistentTrieOf[V]) LongestPrefix(given []byte) (key []byte, value V, ok bool) {
+	return t.trie.LongestPrefix(given)
+}
+
+// Iterating elements from a given start key.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *PersistentTrieOf[V]) Walk(start []byte, handle func(key []byte, value V) bool) bool {
+	return t.trie.Walk(start, handle)
+}
+
+// Iterating elements from the first key that is equal to or greater than a given start key.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *PersistentTrieOf[V]) WalkFrom(start []byte, handle func(key []byte, value V) bool) bool {
+	return t.trie.WalkFrom(start, handle)
+}
+
+// Iterating elements from the first key that is greater than a given start key.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *PersistentTrieOf[V]) WalkAfter(start []byte, handle func(key []byte, value V) bool) bool {
+	return t.trie.WalkAfter(start, handle)
+}
+
+// Iterating elements in reverse order from the last key that is equal to or less than a given start key.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *PersistentTrieOf[V]) WalkReverse(start []byte, handle func(key []byte, value V) bool) bool {
+	return t.trie.WalkReverse(start, handle)
+}
+
+// Iterating elements between `lo` and `hi`.
+// handle is called with arguments key and value (if handle returns `false`, the iteration is aborted)
+func (t *PersistentTrieOf[V]) Range(lo, hi []byte, mode RangeMode, handle func(key []byte, value V) bool) bool {
+	return t.trie.Range(lo, hi, mode, handle)
+}
+
+// return the smallest key in a tree.
+func (t *PersistentTrieOf[V]) Min() (key []byte, value V, ok bool) {
+	return t.trie.Min()
+}
+
+// return the largest key in a tree.
+func (t *PersistentTrieOf[V]) Max() (key []byte, value V, ok bool) {
+	return t.trie.Max()
+}
+
+// return the largest key equal to or less than a given key.
+func (t *PersistentTrieOf[V]) Floor(given []byte) (key []byte, value V, ok bool) {
+	return t.trie.Floor(given)
+}
+
+// return the smallest key equal to or greater than a given key.
+func (t *PersistentTrieOf[V]) Ceiling(given []byte) (key []byte, value V, ok bool) {
+	return t.trie.Ceiling(given)
+}
+
+// return the largest key strictly less than a given key.
+func (t *PersistentTrieOf[V]) Lower(given []byte) (key []byte, value V, ok bool) {
+	return t.trie.Lower(given)
+}
+
+// return the smallest key strictly greater than a given key.
+func (t *PersistentTrieOf[V]) Higher(given []byte) (key []byte, value V, ok bool) {
+	return t.trie.Higher(given)
+}
+
+// return the number of keys less than a given key.
+func (t *PersistentTrieOf[V]) Rank(key []byte) int {
+	return t.trie.Rank(key)
+}
+
+// return the element at a given index in sorted order (starting from 0).
+func (t *PersistentTrieOf[V]) Select(i int) (key []byte, value V, ok bool) {
+	return t.trie.Select(i)
+}
+
+// return the number of keys between `lo` and `hi`.
+func (t *PersistentTrieOf[V]) CountRange(lo, hi []byte, mode RangeMode) int {
+	return t.trie.CountRange(lo, hi, mode)
+}
+
+// dump tree. (for debugging)
+func (t *PersistentTrieOf[V]) Dump(w io.Writer) {
+	t.trie.Dump(w)
+}
+
+// create an empty immutable tree.
+func NewPersistentTrie(opts ...Option) *PersistentTrie {
+	return NewPersistentTrieOf[interface{}](opts...)
+}
+
+// create an empty immutable tree holding values of type V.
+func NewPersistentTrieOf[V any](opts ...Option) *PersistentTrieOf[V] {
+	// an empty version, copying keys instead of using an arena with WithArena
+	t := &PersistentTrieOf[V]{*NewTrieOf[V](opts...)}
+	return t.version(node[V]{}, 0)
+}
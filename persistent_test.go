@@ -0,0 +1,114 @@
+package critbitgo_test
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+
+	"github.com/k-sone/critbitgo"
+)
+
+func dumpPersistentTrie(trie *critbitgo.PersistentTrie) string {
+	buf := bytes.NewBufferString("")
+	trie.Dump(buf)
+	return buf.String()
+}
+
+func TestPersistentTrie(t *testing.T) {
+	keys := []string{"", "a", "aa", "b", "bb", "ab", "ba", "aba", "bab"}
+	versions := []*critbitgo.PersistentTrie{critbitgo.NewPersistentTrie()}
+	for _, key := range keys {
+		v, ok := versions[len(versions)-1].Insert([]byte(key), key)
+		if !ok {
+			t.Errorf("Insert() - failed insert \"%s\"", key)
+		}
+		versions = append(versions, v)
+	}
+
+	// older versions are not changed
+	for i, v := range versions {
+		if s := v.Size(); s != i {
+			t.Errorf("Size() - expected [%d], actual [%d]", i, s)
+		}
+		for j, key := range keys {
+			if value, ok := v.Get([]byte(key)); ok != (j < i) || (ok && value != key) {
+				t.Errorf("Get() - version %d: invalid result %s", i, key)
+			}
+		}
+	}
+
+	// same structure as Trie
+	last := versions[len(versions)-1]
+	if d1, d2 := dumpTrie(buildTrie(t, keys)), dumpPersistentTrie(last); d1 != d2 {
+		t.Errorf("Insert() - different tries\ntrie:\n%s\npersistent:\n%s\n", d1, d2)
+	}
+
+	if v, ok := last.Insert([]byte("a"), nil); ok || v != last {
+		t.Error("Insert() - check exists")
+	}
+	set := last.Set([]byte("a"), 100)
+	if v, _ := set.Get([]byte("a")); v != 100 {
+		t.Errorf("Set() - failed replace - %v", v)
+	}
+	if v, _ := last.Get([]byte("a")); v != "a" {
+		t.Errorf("Set() - changed older version - %v", v)
+	}
+
+	deleted := last
+	for i, key := range keys {
+		var value interface{}
+		var ok bool
+		if deleted, value, ok = deleted.Delete([]byte(key)); !ok || value != key {
+			t.Errorf("Delete() - failed - %s", key)
+		}
+		if deleted.Contains([]byte(key)) {
+			t.Errorf("Delete() - exists - %s", key)
+		}
+		if s := deleted.Size(); s != len(keys)-(i+1) {
+			t.Errorf("Size() - expected [%d], actual [%d]", len(keys)-(i+1), s)
+		}
+	}
+	if v, _, ok := deleted.Delete([]byte("a")); ok || v != deleted {
+		t.Error("Delete() - phantom found")
+	}
+	if s := last.Size(); s != len(keys) {
+		t.Errorf("Delete() - changed older version [%d]", s)
+	}
+	for _, key := range keys {
+		if !last.Contains([]byte(key)) {
+			t.Errorf("Delete() - changed older version - %s", key)
+		}
+	}
+}
+
+func TestPersistentTrieRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(0))
+	genKey := randomKey(random, 4)
+
+	trie := critbitgo.NewTrie()
+	ptrie := critbitgo.NewPersistentTrie(critbitgo.WithOrderStatistics())
+	for i := 0; i < 500; i++ {
+		key := genKey()
+		if random.Intn(3) == 0 {
+			trie.Delete(key)
+			ptrie, _, _ = ptrie.Delete(key)
+		} else {
+			trie.Set(key, i)
+			ptrie = ptrie.Set(key, i)
+		}
+
+		if d1, d2 := dumpTrie(trie), dumpPersistentTrie(ptrie); d1 != d2 {
+			t.Fatalf("different tries\ntrie:\n%s\npersistent:\n%s\n", d1, d2)
+		}
+		var j int
+		for k, v := range ptrie.All() {
+			if r := ptrie.Rank(k); r != j {
+				t.Fatalf("Rank() - %x: expected [%d], actual [%d]", k, j, r)
+			}
+			if value, _ := trie.Get(k); value != v {
+				t.Fatalf("Get() - %x: expected [%v], actual [%v]", k, value, v)
+			}
+			j++
+		}
+	}
+}
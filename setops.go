@@ -0,0 +1,275 @@
+package critbitgo
+
+import (
+	"bytes"
+	"iter"
+)
+
+// Set operations build a new tree sharing unchanged subtrees with the operands.
+// A subtree is shared without being traversed if the other tree has no keys
+// with the common prefix of the subtree, found by descending both trees together.
+// The operands are only read, so set operations may be called concurrently with each other
+// and with other reads of the operands, but not with changes to them.
+// Nodes an operand may still change in place are copied into the new tree, so that subtrees
+// are shared only after the operand has given them up, e.g. by Snapshot.
+// If either operand is in an arena (with WithArena), nothing is shared and keys of both
+// are walked together instead, building the new tree in O(n+m).
+
+// returning a new tree holding keys in either `t` or `other`.
+// If a key is in both trees, its value is `merge(key, a, b)` where `a` is the value in `t`
+// and `b` is the value in `other`. If `merge` is nil, the value in `t` is used.
+// The new tree has the same options as `t`.
+func (t *TrieOf[V]) Union(other *TrieOf[V], merge func(key []byte, a, b V) V) *TrieOf[V] {
+	if t.opts.arena || other.arena != nil {
+		return t.mergeKeys(other, func(key []byte, a, b V, inT, inOther bool) (V, bool) {
+			if !inT {
+				return b, true
+			}
+			if inOther && merge != nil {
+				return merge(key, a, b), true
+			}
+			return a, true
+		})
+	}
+	r := &TrieOf[V]{size: t.size, opts: t.opts, gen: generation.Add(1)}
+	if t.size > 0 {
+		r.root = r.share(t.root, t.gen)
+	}
+	if other.size == 0 {
+		return r
+	}
+
+	// the size is an upper bound until keys in both trees are counted
+	r.size += other.size
+	r.size -= r.union(&other.root, other.gen, !r.opts.counted || other.opts.counted, merge)
+	return r
+}
+
+// returning the subtree `n` of another tree of generation `gen` to link into the tree,
+// copying the nodes which the other tree may change in place.
+// Internal nodes of other generations are shared with snapshots, and so are their subtrees.
+// External nodes are replaced in place only by trees which have never been snapshotted.
+func (t *TrieOf[V]) share(n node[V], gen uint64) node[V] {
+	if in := n.internal; in != nil {
+		if in.gen != gen {
+			return n
+		}
+		c := t.copyInternal(in)
+		c.gen = t.gen
+		for i := 0; i < 2; i++ {
+			c.child[i] = t.share(in.child[i], gen)
+		}
+		return node[V]{internal: c}
+	}
+	if gen != 0 {
+		return n
+	}
+	return node[V]{external: t.newExternal(n.external.key, n.external.value)}
+}
+
+// merging the subtree `n` of another tree of generation `gen`, returning the number of keys already in the tree.
+// If `graft` is false, counts of internal nodes of `n` are not valid and they are not shared.
+func (t *TrieOf[V]) union(n *node[V], gen uint64, graft bool, merge func([]byte, V, V) V) int {
+	// an empty tree
+	if t.root.internal == nil && t.root.external == nil {
+		if n.internal != nil && !graft {
+			return t.union(&n.internal.child[0], gen, graft, merge) + t.union(&n.internal.child[1], gen, graft, merge)
+		}
+		t.root = t.share(*n, gen)
+		return 0
+	}
+
+	if in := n.internal; in != nil {
+		key := n.first().key
+		if !graft || t.prefixed(key[:in.offset]) != nil {
+			return t.union(&in.child[0], gen, graft, merge) + t.union(&in.child[1], gen, graft, merge)
+		}
+		crit := t.newInternal(internal[V]{critBit: *t.critNode(key), gen: t.gen})
+		t.link(crit, t.share(*n, gen), key, n.count())
+		return 0
+	}
+
+	e := n.external
+	crit := t.critNode(e.key)
+	if crit == nil {
+		// already exists in the tree
+		if merge != nil {
+			p := t.search(e.key)
+			t.insert(e.key, merge(e.key, p.external.value, e.value), true)
+		}
+		return 1
+	}
+	t.link(t.newInternal(internal[V]{critBit: *crit, gen: t.gen}), t.share(*n, gen), e.key, 1)
+	return 0
+}
+
+// returning a new tree holding keys in both `t` and `other`.
+// The value of a key is `merge(key, a, b)` where `a` is the value in `t`
+// and `b` is the value in `other`. If `merge` is nil, the value in `t` is used.
+// The new tree has the same options as `t`.
+func (t *TrieOf[V]) Intersect(other *TrieOf[V], merge func(key []byte, a, b V) V) *TrieOf[V] {
+	if t.opts.arena || other.arena != nil {
+		return t.mergeKeys(other, func(key []byte, a, b V, inT, inOther bool) (V, bool) {
+			if inT && inOther && merge != nil {
+				return merge(key, a, b), true
+			}
+			return a, inT && inOther
+		})
+	}
+	return t.filter(other, false, func(r *TrieOf[V], e, oe *external[V]) *external[V] {
+		switch {
+		case oe == nil:
+			return nil
+		case merge == nil:
+			return e
+		}
+		return r.newExternal(e.key, merge(e.key, e.value, oe.value))
+	})
+}
+
+// returning a new tree holding keys in `t` but not in `other`.
+// The new tree has the same options as `t`.
+func (t *TrieOf[V]) Difference(other *TrieOf[V]) *TrieOf[V] {
+	if t.opts.arena || other.arena != nil {
+		return t.mergeKeys(other, func(key []byte, a, b V, inT, inOther bool) (V, bool) {
+			return a, inT && !inOther
+		})
+	}
+	return t.filter(other, true, func(r *TrieOf[V], e, oe *external[V]) *external[V] {
+		if oe != nil {
+			return nil
+		}
+		return e
+	})
+}
+
+// returning a new tree holding keys in either `t` or `other` but not in both.
+// The new tree has the same options as `t`.
+func (t *TrieOf[V]) SymmetricDifference(other *TrieOf[V]) *TrieOf[V] {
+	return t.Difference(other).Union(other.Difference(t), nil)
+}
+
+// returning a new tree holding keys in either `t` or `other` kept by `f`, by walking keys of both trees together.
+// `f` is called with a key, its values in `t` and `other` and whether it is in each of them,
+// and returns the value of the key in the new tree and whether to keep it.
+func (t *TrieOf[V]) mergeKeys(other *TrieOf[V], f func(key []byte, a, b V, inT, inOther bool) (V, bool)) *TrieOf[V] {
+	r := &TrieOf[V]{opts: t.opts}
+	b := newBuilder(r)
+	add := func(key []byte, a, o V, inT, inOther bool) {
+		if v, ok := f(key, a, o, inT, inOther); ok {
+			// keys are added in ascending order, never failing
+			b.add(r.storedKey(key), v)
+		}
+	}
+
+	next, stop := iter.Pull2(other.All())
+	defer stop()
+	ko, vo, ok := next()
+	var zero V
+	t.Allprefixed(nil, func(key []byte, value V) bool {
+		for ; ok && bytes.Compare(ko, key) < 0; ko, vo, ok = next() {
+			add(ko, zero, vo, false, true)
+		}
+		if ok && bytes.Equal(ko, key) {
+			add(key, value, vo, true, true)
+			ko, vo, ok = next()
+		} else {
+			add(key, value, zero, true, false)
+		}
+		return true
+	})
+	for ; ok; ko, vo, ok = next() {
+		add(ko, zero, vo, false, true)
+	}
+	b.finish()
+	return r
+}
+
+// returning a new tree holding keys of `t` kept by `f`, which is called with the new tree, the external node
+// of a key and that of `other` (nil if not found), and returns the external node to keep or nil.
+// Subtrees of keys not in `other` are kept if `disjoint` is true, or dropped otherwise.
+func (t *TrieOf[V]) filter(other *TrieOf[V], disjoint bool, f func(r *TrieOf[V], e, oe *external[V]) *external[V]) *TrieOf[V] {
+	r := &TrieOf[V]{opts: t.opts, gen: generation.Add(1)}
+	if t.size == 0 {
+		return r
+	}
+
+	var o *node[V]
+	if other.size > 0 {
+		o = &other.root
+	}
+	var kept, dropped int
+	r.root = r.filterNode(&t.root, t.gen, o, disjoint, f, &kept, &dropped)
+	if disjoint {
+		r.size = t.size - dropped
+	} else {
+		r.size = kept
+	}
+	return r
+}
+
+// filtering the subtree `n` of another tree of generation `gen`,
+// where `o` is the top node of keys of a third tree which may be in `n`.
+// `kept` and `dropped` are increased by the number of keys examined by `f`.
+func (t *TrieOf[V]) filterNode(n *node[V], gen uint64, o *node[V], disjoint bool, f func(r *TrieOf[V], e, oe *external[V]) *external[V], kept, dropped *int) node[V] {
+	in := n.internal
+	if in == nil {
+		var oe *external[V]
+		if o != nil {
+			for o.internal != nil {
+				o = &o.internal.child[o.internal.direction(n.external.key)]
+			}
+			if bytes.Equal(o.external.key, n.external.key) {
+				oe = o.external
+			}
+		}
+		if e := f(t, n.external, oe); e != nil {
+			*kept += 1
+			if e == n.external {
+				return t.share(*n, gen)
+			}
+			return node[V]{external: e}
+		}
+		*dropped += 1
+		return node[V]{}
+	}
+
+	if o != nil {
+		o = prefixedNode(o, n.first().key[:in.offset])
+	}
+	if o == nil {
+		// no keys of the other tree in the subtree
+		if disjoint {
+			return t.share(*n, gen)
+		}
+		return node[V]{}
+	}
+
+	c0 := t.filterNode(&in.child[0], gen, o, disjoint, f, kept, dropped)
+	c1 := t.filterNode(&in.child[1], gen, o, disjoint, f, kept, dropped)
+	switch {
+	case c0.internal == nil && c0.external == nil:
+		return c1
+	case c1.internal == nil && c1.external == nil:
+		return c0
+	case c0 == in.child[0] && c1 == in.child[1] && in.gen != gen:
+		return *n
+	}
+	c := t.newInternal(internal[V]{
+		child:   [2]node[V]{c0, c1},
+		critBit: in.critBit,
+		gen:     t.gen,
+	})
+	if t.opts.counted {
+		c.counted().count = c0.count() + c1.count()
+	}
+	return node[V]{internal: c}
+}
+
+// returning the external node of the smallest key in the subtree.
+func (n *node[V]) first() *external[V] {
+	for n.internal != nil {
+		n = &n.internal.child[0]
+	}
+	return n.external
+}
@@ -0,0 +1,91 @@
+package critbitgo
+
+import (
+	"unsafe"
+)
+
+// Stats is the memory usage of a tree returned by Stats.
+type Stats struct {
+	Internals int     // number of internal nodes
+	Externals int     // number of external nodes, which is the number of keys
+	KeyBytes  int     // total length of keys
+	MaxDepth  int     // maximum number of internal nodes from the root to a key
+	AvgDepth  float64 // average number of internal nodes from the root to a key
+	Bytes     int     // estimated bytes of nodes and keys, not including memory referenced by values
+}
+
+// return the memory usage of a tree by traversing all nodes.
+// Nodes shared with snapshots are counted in each tree.
+// With WithArena, Bytes is the size of the arena, including slots and keys no longer in the tree.
+func (t *TrieOf[V]) Stats() (s Stats) {
+	if t.size == 0 {
+		return
+	}
+	var depths int
+	if a := t.arena; a != nil {
+		a.stats(a.root, 0, &s, &depths)
+		s.AvgDepth = float64(depths) / float64(s.Externals)
+		s.Bytes = len(a.internals)*int(unsafe.Sizeof(arenaInternal{})) + len(a.counts)*int(unsafe.Sizeof(0)) +
+			len(a.externals)*int(unsafe.Sizeof(arenaExternal[V]{})) + len(a.keys)
+		return
+	}
+	stats(&t.root, 0, &s, &depths)
+	s.AvgDepth = float64(depths) / float64(s.Externals)
+	internalSize := unsafe.Sizeof(internal[V]{})
+	if t.opts.counted {
+		internalSize = unsafe.Sizeof(countedInternal[V]{})
+	}
+	s.Bytes = s.Internals*int(internalSize) +
+		s.Externals*int(unsafe.Sizeof(external[V]{})) + s.KeyBytes
+	return
+}
+
+func stats[V any](n *node[V], depth int, s *Stats, depths *int) {
+	if in := n.internal; in != nil {
+		s.Internals += 1
+		stats(&in.child[0], depth+1, s, depths)
+		stats(&in.child[1], depth+1, s, depths)
+		return
+	}
+	s.Externals += 1
+	s.KeyBytes += len(n.external.key)
+	if depth > s.MaxDepth {
+		s.MaxDepth = depth
+	}
+	*depths += depth
+}
+
+func (a *arena[V]) stats(ref uint32, depth int, s *Stats, depths *int) {
+	if ref&arenaExternalFlag == 0 {
+		s.Internals += 1
+		a.stats(a.internals[ref].child[0], depth+1, s, depths)
+		a.stats(a.internals[ref].child[1], depth+1, s, depths)
+		return
+	}
+	s.Externals += 1
+	s.KeyBytes += len(a.key(ref))
+	if depth > s.MaxDepth {
+		s.MaxDepth = depth
+	}
+	*depths += depth
+}
+
+// Returns the memory usage of the table by traversing all routes.
+// A key of a route is the IP address followed by a byte of the mask length,
+// and depths are those in the table of each family.
+func (n *NetOf[V]) Stats() (s Stats) {
+	var depths float64
+	for _, t := range n.tries {
+		ts := t.Stats()
+		s.Internals += ts.Internals
+		s.Externals += ts.Externals
+		s.KeyBytes += ts.KeyBytes
+		s.MaxDepth = max(s.MaxDepth, ts.MaxDepth)
+		s.Bytes += ts.Bytes
+		depths += ts.AvgDepth * float64(ts.Externals)
+	}
+	if s.Externals > 0 {
+		s.AvgDepth = depths / float64(s.Externals)
+	}
+	return
+}
@@ -0,0 +1,161 @@
+package critbitgo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrInvalidTree is returned by Validate when a tree is broken.
+var ErrInvalidTree = errors.New("critbitgo: invalid tree")
+
+// check that the tree is well-formed by traversing all nodes:
+//   - every node is either an internal node with two children or an external node
+//   - critical bits are in increasing order along every path
+//   - the critical bit of an internal node is the first bit differing between its subtrees
+//   - every key is reached by searching for it, so that `cont` of internal nodes is consistent
+//   - keys are in ascending order, and the number of keys is the size (and counts of subtrees)
+//   - with WithArena, every handle refers to a slot in use by no other node
+//
+// If not, returns an error wrapping ErrInvalidTree.
+func (t *TrieOf[V]) Validate() error {
+	if t.size == 0 {
+		if t.root.internal != nil || t.root.external != nil || t.arena != nil {
+			return fmt.Errorf("%w: root of an empty tree is not empty", ErrInvalidTree)
+		}
+		return nil
+	}
+
+	root := t.root
+	if a := t.arena; a != nil {
+		if root.internal != nil || root.external != nil {
+			return fmt.Errorf("%w: tree in an arena has a root node", ErrInvalidTree)
+		}
+		if err := a.check(); err != nil {
+			return err
+		}
+		root = a.expand(a.root)
+	}
+	v := &validator[V]{counted: t.opts.counted}
+	if _, err := v.check(&root); err != nil {
+		return err
+	}
+	if v.keys != t.size {
+		return fmt.Errorf("%w: size is %d, but %d keys found", ErrInvalidTree, t.size, v.keys)
+	}
+	return nil
+}
+
+type validator[V any] struct {
+	counted bool
+	path    []*internal[V] // ancestors of the current node
+	dirs    []int          // directions taken at ancestors
+	prev    []byte         // the last key found
+	keys    int            // number of keys found
+}
+
+// checking the subtree `n`, returning the number of keys in it.
+func (v *validator[V]) check(n *node[V]) (int, error) {
+	switch {
+	case n.internal != nil && n.external != nil:
+		return 0, fmt.Errorf("%w: node is both internal and external", ErrInvalidTree)
+	case n.internal == nil && n.external == nil:
+		return 0, fmt.Errorf("%w: empty node in a non-empty tree", ErrInvalidTree)
+	}
+
+	if in := n.internal; in != nil {
+		if l := len(v.path); l > 0 && (!v.path[l-1].above(&in.critBit) || in.above(&v.path[l-1].critBit)) {
+			p := v.path[l-1]
+			return 0, fmt.Errorf("%w: critical bit (%d, %02x, %v) is not below the parent (%d, %02x, %v)",
+				ErrInvalidTree, in.offset, in.bit, in.cont, p.offset, p.bit, p.cont)
+		}
+		for i := 0; i < 2; i++ {
+			if in.child[i].internal == nil && in.child[i].external == nil {
+				return 0, fmt.Errorf("%w: empty child of an internal node", ErrInvalidTree)
+			}
+		}
+		offset, bit, cont := in.child[0].first().criticalBit(in.child[1].first().key)
+		if offset != in.offset || cont != in.cont || (!cont && bit != in.bit) {
+			return 0, fmt.Errorf("%w: critical bit is (%d, %02x, %v), but subtrees differ at (%d, %02x, %v)",
+				ErrInvalidTree, in.offset, in.bit, in.cont, offset, bit, cont)
+		}
+
+		var count int
+		for i := 0; i < 2; i++ {
+			v.path = append(v.path, in)
+			v.dirs = append(v.dirs, i)
+			c, err := v.check(&in.child[i])
+			if err != nil {
+				return 0, err
+			}
+			v.path = v.path[:len(v.path)-1]
+			v.dirs = v.dirs[:len(v.dirs)-1]
+			count += c
+		}
+		if v.counted && in.counted().count != count {
+			return 0, fmt.Errorf("%w: count of a subtree is %d, but %d keys found", ErrInvalidTree, in.counted().count, count)
+		}
+		return count, nil
+	}
+
+	key := n.external.key
+	for i, p := range v.path {
+		if p.direction(key) != v.dirs[i] {
+			return 0, fmt.Errorf("%w: key %x is in the wrong subtree of (%d, %02x, %v)",
+				ErrInvalidTree, key, p.offset, p.bit, p.cont)
+		}
+	}
+	if v.keys > 0 && bytes.Compare(v.prev, key) >= 0 {
+		return 0, fmt.Errorf("%w: key %x follows %x", ErrInvalidTree, key, v.prev)
+	}
+	v.prev = key
+	v.keys += 1
+	return 1, nil
+}
+
+// checking that handles reachable from the root refer to distinct slots in use,
+// so that the tree can be expanded and checked in the same way as other trees.
+func (a *arena[V]) check() error {
+	if a.counted && len(a.counts) != len(a.internals) {
+		return fmt.Errorf("%w: %d counts for %d internal nodes", ErrInvalidTree, len(a.counts), len(a.internals))
+	}
+	used := [2][]bool{make([]bool, len(a.internals)), make([]bool, len(a.externals))}
+	use := func(kind int, i uint32) error {
+		if int(i) >= len(used[kind]) {
+			return fmt.Errorf("%w: handle %d of %d nodes is out of range", ErrInvalidTree, i, len(used[kind]))
+		}
+		if used[kind][i] {
+			return fmt.Errorf("%w: slot %d is used twice", ErrInvalidTree, i)
+		}
+		used[kind][i] = true
+		return nil
+	}
+	for kind := 0; kind < 2; kind++ {
+		for _, i := range a.free[kind] {
+			if err := use(kind, i); err != nil {
+				return err
+			}
+		}
+	}
+
+	refs := []uint32{a.root}
+	for len(refs) > 0 {
+		ref := refs[len(refs)-1]
+		refs = refs[:len(refs)-1]
+		if ref&arenaExternalFlag != 0 {
+			i := ref &^ arenaExternalFlag
+			if err := use(1, i); err != nil {
+				return err
+			}
+			if e := &a.externals[i]; e.off < 0 || e.len < 0 || e.off+e.len > len(a.keys) {
+				return fmt.Errorf("%w: key of %d bytes at %d is out of %d bytes", ErrInvalidTree, e.len, e.off, len(a.keys))
+			}
+			continue
+		}
+		if err := use(0, ref); err != nil {
+			return err
+		}
+		refs = append(refs, a.internals[ref].child[:]...)
+	}
+	return nil
+}